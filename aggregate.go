@@ -0,0 +1,25 @@
+package main
+
+import (
+	"io"
+)
+
+// failStmt returns the statement used to handle errExpr failing, either
+// returning it right away or adding it to our list of errors.
+func failStmt(aggregate bool, errExpr string) string {
+	if aggregate {
+		return "errs.Add(" + errExpr + ")"
+	}
+
+	return "return c, " + errExpr
+}
+
+// writeErrCheck writes the handling of a possible error stored in err.
+func writeErrCheck(w io.Writer, aggregate bool) {
+	if aggregate {
+		writeF(w, "\n%v", failStmt(aggregate, "err"))
+		return
+	}
+
+	writeF(w, "\nif err != nil {\n%v\n}", failStmt(aggregate, "err"))
+}
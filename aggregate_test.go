@@ -0,0 +1,41 @@
+package main
+
+import (
+	"testing"
+)
+
+func TestGeneratedAggregate(t *testing.T) {
+	src := `package main
+
+type Config struct {
+	Min  int ` + "`default:\"0\"`" + `
+	Max  int ` + "`default:\"10\" gt:\"Min\"`" + `
+	Name string
+	Db   *DbConfig
+}
+
+type DbConfig struct {
+	File string
+}
+`
+
+	generated := mustGenerate(t, src, GenConfig{Aggregate: true})
+	hasAll(t, generated,
+		"errs ConfigErrors",
+		`c.Name, err = parseRequired(ld, "NAME", convString)`,
+		"errs.Add(err)",
+		`c.Db, err = loadDbConfig(ld, "DB")`,
+		// rules would compare fields left at zero after failing to parse
+		"if len(errs) == 0 {\n\t\tif !(c.Max > c.Min) {\n\t\t\terrs.Add(describeField(&FieldError{Key: \"MAX\"",
+		"return c, errs.Err()",
+	)
+
+	generated = mustGenerate(t, src, GenConfig{})
+	hasAll(t, generated,
+		"if err != nil {\n\t\treturn c, err\n\t}",
+		"if !(c.Max > c.Min) {\n\t\treturn c, describeField(&FieldError{Key: \"MAX\"",
+		// only the default loader collects errors without --aggregate
+		"func loadConfig(ld *loader) (*Config, error) {\n\tvar err error\n",
+	)
+	hasNone(t, generated, "errs.Add(describeField")
+}
@@ -0,0 +1,50 @@
+package main
+
+import (
+	"fmt"
+	"strings"
+)
+
+// aliasArgs returns the extra parser args for our aliases, each one is
+// prefixed the same way as our key.
+func (f *Field) aliasArgs() string {
+	var sb strings.Builder
+	for _, alias := range f.aliases {
+		sb.WriteString(", ")
+		sb.WriteString(f.aliasKeyExpr(alias))
+	}
+
+	return sb.String()
+}
+
+func (f *Field) aliasKeyExpr(alias string) string {
+	if f.rootTypeField {
+		return fmt.Sprintf("%q", joinKey(f.rootPrefix, alias))
+	}
+
+	return fmt.Sprintf("prefix + %q", "_"+alias)
+}
+
+// isDeprecated checks for a "Deprecated:" paragraph in our docs the same
+// way go doc does.
+func (f *Field) isDeprecated() bool {
+	return f.deprecation() != ""
+}
+
+// deprecation returns our "Deprecated:" paragraph on a single line, or an
+// empty string if we are not deprecated.
+func (f *Field) deprecation() string {
+	var para []string
+	for _, line := range strings.Split(f.docs, "\n") {
+		switch {
+		case strings.HasPrefix(line, "Deprecated:"):
+			para = append(para, line)
+		case len(para) > 0 && strings.TrimSpace(line) == "":
+			return strings.Join(strings.Fields(strings.Join(para, " ")), " ")
+		case len(para) > 0:
+			para = append(para, line)
+		}
+	}
+
+	return strings.Join(strings.Fields(strings.Join(para, " ")), " ")
+}
@@ -1,10 +1,12 @@
 package main
 
 import (
-	"fmt"
 	"io"
+	"sort"
 	"strings"
 	"unicode"
+
+	"github.com/miniscruff/genenv/envrt"
 )
 
 type (
@@ -13,14 +15,6 @@ type (
 	}
 
 	Import string
-	Parser struct {
-		ReturnType  string
-		IsSlice     bool
-		IsRequired  bool
-		Imports     map[string]string
-		Errs        *ErrorCache
-		ImportCache *ImportCache
-	}
 
 	ErrorDef struct {
 		VarName string
@@ -31,14 +25,23 @@ type (
 		Cacher[string]
 	}
 
-	ParserCache struct {
-		Cacher[Parser]
-	}
-
 	ErrorCache struct {
 		Cacher[ErrorDef]
 	}
 
+	// Template is a static block of code written once per generated file
+	Template struct {
+		Code    string
+		Imports []string
+		Errs    []ErrorDef
+		// Deps are the other templates used by Code
+		Deps []string
+	}
+
+	TemplateCache struct {
+		Cacher[Template]
+	}
+
 	QueueCache struct {
 		values []string
 		seen   map[string]struct{}
@@ -81,6 +84,17 @@ func (c *Cacher[T]) Add(key string, value T) {
 	c.values[key] = value
 }
 
+// Keys returns the cached keys in sorted order so generated output is stable.
+func (c *Cacher[T]) Keys() []string {
+	keys := make([]string, 0, len(c.values))
+	for k := range c.values {
+		keys = append(keys, k)
+	}
+
+	sort.Strings(keys)
+	return keys
+}
+
 func (c *ImportCache) Write(w io.Writer) error {
 	err := writeF(w, "import (\n")
 	// only check the write error once
@@ -88,22 +102,42 @@ func (c *ImportCache) Write(w io.Writer) error {
 		return err
 	}
 
-	for _, imp := range c.values {
-		writeF(w, "\"%v\"\n", imp)
+	// imports may be added by name and by path
+	written := make(map[string]bool)
+	for _, k := range c.Keys() {
+		if v := c.values[k]; !written[v] {
+			written[v] = true
+			writeF(w, "\"%v\"\n", v)
+		}
 	}
 
 	writeF(w, ")\n\n")
 	return nil
 }
 
+// Keep removes every import whose path is not in used.
+func (c *ImportCache) Keep(used map[string]bool) {
+	for k, v := range c.values {
+		if !used[v] {
+			delete(c.values, k)
+		}
+	}
+}
+
 func (c *ErrorCache) Write(w io.Writer) error {
+	// errors may all be declared by another file of the package
+	if len(c.values) == 0 {
+		return nil
+	}
+
 	err := writeF(w, "var (\n")
 	// only check the write error once
 	if err != nil {
 		return err
 	}
 
-	for _, e := range c.values {
+	for _, k := range c.Keys() {
+		e := c.values[k]
 		writeF(w, "%v = errors.New(\"%v\")\n", e.VarName, e.Desc)
 	}
 
@@ -111,99 +145,57 @@ func (c *ErrorCache) Write(w io.Writer) error {
 	return nil
 }
 
-func (p Parser) RequiredStr() string {
-	if p.IsRequired {
-		return "Required"
-	}
-	return "Optional"
-}
+// Use adds the envrt templates named along with every template they use.
+func (c *TemplateCache) Use(names ...string) {
+	for _, name := range names {
+		if _, found := c.values[name]; found {
+			continue
+		}
 
-func (p Parser) ArgsList() string {
-	argsList := "def, key string"
-	if p.IsRequired {
-		argsList = "key string"
-	}
-	return argsList
-}
+		t, found := runtimeTemplates[name]
+		if !found {
+			panic("unknown envrt template: " + name)
+		}
 
-func (p Parser) FuncName() string {
-	sliceStr := ""
-	if p.IsSlice {
-		sliceStr = "Slice"
+		c.Add(name, t)
+		c.Use(t.Deps...)
 	}
-
-	return fmt.Sprintf(
-		"Parse%v%v%v",
-		strings.ReplaceAll(strings.Title(p.ReturnType), ".", ""),
-		sliceStr,
-		p.RequiredStr(),
-	)
 }
 
-func (p *Parser) Write(w io.Writer) error {
-	funcName := p.FuncName()
-	if _, found := convMap[p.ReturnType]; !found {
-		return fmt.Errorf("unknown type: %v", p.ReturnType)
-	}
-
-	for _, imp := range convMap[p.ReturnType].Imports {
-		p.ImportCache.Add(imp, imp)
-	}
-	for _, e := range convMap[p.ReturnType].Errs {
-		p.Errs.Add(e.VarName, e)
-	}
+// Helpers maps the name of every template and error declared in a
+// generated file to its name in envrt.
+func (c *TemplateCache) Helpers() map[string]string {
+	helpers := make(map[string]string)
+	for name, t := range c.values {
+		if t.Code != "" {
+			helpers[inlineName(name)] = name
+		}
 
-	writeF(
-		w,
-		"func %v(%v) (%v, error) {\nv, ok := os.LookupEnv(key)\nif !ok {\n",
-		funcName,
-		p.ArgsList(),
-		p.ReturnType,
-	)
-
-	if p.IsRequired {
-		p.ImportCache.Add("errors", "errors")
-		p.ImportCache.Add("fmt", "fmt")
-		p.Errs.Add("keyNotFound", ErrorDef{
-			VarName: "ErrKeyNotFound",
-			Desc:    "env var key not found",
-		})
-
-		writeF(
-			w,
-			"return %v, fmt.Errorf(\"%%w: %%v\", ErrKeyNotFound, key)",
-			convMap[p.ReturnType].DefaultValue,
-		)
-	} else {
-		writeF(
-			w,
-			"v = def",
-		)
+		for _, e := range t.Errs {
+			helpers[e.VarName] = e.VarName
+		}
 	}
 
-	writeF(
-		w,
-		"\n}\n\n",
-	)
+	return helpers
+}
 
-	writeF(
-		w,
-		convMap[p.ReturnType].ConvReturnFormat,
-		"v",
-	)
+func (c *TemplateCache) Write(w io.Writer, imports *ImportCache, errs *ErrorCache) error {
+	for _, k := range c.Keys() {
+		t := c.values[k]
+		logLine("template:", k)
+		for _, imp := range t.Imports {
+			imports.Add(imp, imp)
+		}
 
-	if p.ReturnType != "string" {
-		p.ImportCache.Add("strconv", "strconv")
-	}
+		for _, e := range t.Errs {
+			errs.Add(e.VarName, e)
+		}
 
-	writeF(w, "\n}\n\n")
-	return nil
-}
+		if t.Code == "" {
+			continue
+		}
 
-func (c *ParserCache) Write(w io.Writer) error {
-	for _, parser := range c.values {
-		logLine("parser:", parser.FuncName())
-		if err := parser.Write(w); err != nil {
+		if err := writeF(w, "%v\n\n", strings.TrimSpace(t.Code)); err != nil {
 			return err
 		}
 	}
@@ -212,48 +204,47 @@ func (c *ParserCache) Write(w io.Writer) error {
 }
 
 type ConvInfo struct {
-	DefaultValue     string
-	ConvReturnFormat string
-	Imports          []string
-	Errs             []ErrorDef
+	// Conv and Format are the envrt funcs converting a single value and
+	// formatting it back so converting it again results in an equal value
+	Conv   string
+	Format string
+	// Check parses a value the same way Conv does, used to check default
+	// tags while generating
+	Check func(string) error
+	// Literal parses a value the same way Conv does and returns it as a
+	// go literal, used to compare fields to a value in rules
+	Literal func(string) (string, error)
+	// Ordered types can be compared with gt, gte, lt and lte
+	Ordered bool
 }
 
 var convMap = map[string]ConvInfo{
 	"string": {
-		DefaultValue:     "\"\"",
-		ConvReturnFormat: "return %v, nil",
+		Conv:    "ConvString",
+		Format:  "FormatString",
+		Check:   checkWith(envrt.ConvString),
+		Literal: literalWith(envrt.ConvString),
+		Ordered: true,
 	},
 	"int": {
-		DefaultValue:     "0",
-		ConvReturnFormat: "v64, err := strconv.ParseInt(%v, 10, 64)\nif err != nil {\nreturn 0, err\n}\n\nreturn int(v64), nil",
+		Conv:    "ConvInt",
+		Format:  "FormatInt",
+		Check:   checkWith(envrt.ConvInt),
+		Literal: literalWith(envrt.ConvInt),
+		Ordered: true,
 	},
 	"bool": {
-		DefaultValue: "false",
-		Imports:      []string{"strings", "fmt", "errors"},
-		Errs: []ErrorDef{
-			{
-				VarName: "ErrInvalidBool",
-				Desc:    "invalid bool value",
-			},
-		},
-		ConvReturnFormat: `switch strings.ToLower(%v) {
-		case "y", "yes", "true", "t", "1", "on":
-			return true, nil
-		case "n", "no", "false", "f", "0", "off":
-			return false, nil
-		default:
-			return false, fmt.Errorf("%%w: %%v", ErrInvalidBool, v)
-		}`,
+		Conv:    "ConvBool",
+		Format:  "FormatBool",
+		Check:   checkWith(envrt.ConvBool),
+		Literal: literalWith(envrt.ConvBool),
 	},
 	"time.Duration": {
-		DefaultValue: "0",
-		Imports:      []string{"time"},
-		ConvReturnFormat: `vd, err := time.ParseDuration(%v)
-		if err != nil {
-			return 0, err
-		}
-
-		return vd, nil`,
+		Conv:    "ConvTimeDuration",
+		Format:  "FormatTimeDuration",
+		Check:   checkWith(envrt.ConvTimeDuration),
+		Literal: literalWith(envrt.ConvTimeDuration),
+		Ordered: true,
 	},
 }
 
@@ -0,0 +1,147 @@
+package main
+
+import (
+	"fmt"
+
+	"github.com/miniscruff/genenv/envrt"
+)
+
+// rootKey is a field of a root type along with its full key.
+type rootKey struct {
+	key   string
+	field *Field
+}
+
+// collectKeys returns every field of our root type in declaration order,
+// nested types are walked with their full prefix.
+func collectKeys(builders map[string]*StructBuilder, rootType, rootPrefix string) []rootKey {
+	var keys []rootKey
+
+	var collect func(name, prefix string)
+	collect = func(name, prefix string) {
+		b, found := builders[name]
+		if !found {
+			return
+		}
+
+		for _, f := range b.order {
+			key := joinKey(prefix, f.envKey)
+			if f.customType {
+				collect(f.typeName, key)
+			} else {
+				keys = append(keys, rootKey{key: key, field: f})
+			}
+		}
+	}
+	collect(rootType, rootPrefix)
+
+	return keys
+}
+
+// collectDefaults is collectKeys for only the fields with a default tag.
+func collectDefaults(builders map[string]*StructBuilder, rootType, rootPrefix string) []rootKey {
+	var defaults []rootKey
+	for _, rk := range collectKeys(builders, rootType, rootPrefix) {
+		if rk.field.hasDefault {
+			defaults = append(defaults, rk)
+		}
+	}
+
+	return defaults
+}
+
+// checkWith returns a check parsing values with conv.
+func checkWith[T any](conv func(string) (T, error)) func(string) error {
+	return func(v string) error {
+		_, err := conv(v)
+		return err
+	}
+}
+
+// literalWith returns a literal func parsing values with conv, the go
+// syntax of each supported type is also a valid untyped constant.
+func literalWith[T any](conv func(string) (T, error)) func(string) (string, error) {
+	return func(v string) (string, error) {
+		pv, err := conv(v)
+		if err != nil {
+			return "", err
+		}
+
+		return fmt.Sprintf("%#v", pv), nil
+	}
+}
+
+// checkDefaultValues parses the default tag of every key of our root type
+// the same way it is loaded and runs its validation tags on it, so a bad
+// default fails generating instead of loading. Defaults referencing keys without a default can only be
+// checked once loaded and are skipped.
+func checkDefaultValues(builders map[string]*StructBuilder, rootType, rootPrefix string) error {
+	rootDefaults := collectDefaults(builders, rootType, rootPrefix)
+	defaults := envrt.MapLookup{}
+	for _, rd := range rootDefaults {
+		defaults[rd.key] = rd.field.defaultValue
+	}
+
+	for _, rd := range rootDefaults {
+		v, err := envrt.ExpandRefs(envrt.MapLookup{}, defaults, rootPrefix, rd.field.defaultValue, []string{rd.key})
+		if err != nil {
+			logLine("skipping default check of", rd.key, "-", err)
+			continue
+		}
+
+		err = rd.field.checkValue(v)
+		if err == nil {
+			err = rd.field.allowsValue(rd.key, v)
+		}
+
+		if err != nil {
+			return fmt.Errorf(
+				"invalid default for %v (%v.%v %v): %w",
+				rd.key,
+				rd.field.structName,
+				rd.field.goPath,
+				rd.field.typeName,
+				err,
+			)
+		}
+	}
+
+	return nil
+}
+
+// checkValue parses v as a value of our field, splitting slices the same
+// way the generated parsers do.
+func (f *Field) checkValue(v string) error {
+	info, found := convMap[f.typeName]
+	if !found {
+		return fmt.Errorf("unknown type: %v", f.typeName)
+	}
+
+	if !f.slice {
+		return info.Check(v)
+	}
+
+	if v == "" {
+		return nil
+	}
+
+	for _, part := range envrt.SplitSlice(v) {
+		if err := info.Check(part); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// allowsValue runs our validation tags on v of key, which checkValue
+// already parsed.
+func (f *Field) allowsValue(key, v string) error {
+	for _, check := range f.checks {
+		if !check.Allows(v) {
+			return &envrt.ValidationError{Key: key, Constraint: check.Constraint}
+		}
+	}
+
+	return nil
+}
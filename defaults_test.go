@@ -0,0 +1,189 @@
+package main
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestCheckDefaultValues(t *testing.T) {
+	for _, tc := range []struct {
+		name    string
+		fields  string
+		cfg     GenConfig
+		wantErr string
+	}{
+		{
+			name:   "valid",
+			fields: "Port int `default:\"80\"`\nDebug bool `default:\"true\"`\nWait time.Duration `default:\"1m\"`\nHosts []string `default:\"a, b\"`",
+		},
+		{
+			name:    "int",
+			fields:  "Port int `default:\"abc\"`",
+			wantErr: "invalid default for PORT (Config.Port int)",
+		},
+		{
+			name:    "bool",
+			fields:  "Debug bool `default:\"maybe\"`",
+			wantErr: "invalid default for DEBUG (Config.Debug bool)",
+		},
+		{
+			name:    "duration",
+			fields:  "Wait time.Duration `default:\"10\"`",
+			wantErr: "invalid default for WAIT (Config.Wait time.Duration)",
+		},
+		{
+			name:    "slice item",
+			fields:  "Ports []int `default:\"80, http\"`",
+			wantErr: "invalid default for PORTS (Config.Ports int)",
+		},
+		{
+			name:    "expanded",
+			fields:  "Base int `default:\"8\"`\nPort int `default:\"${BASE}0x\"`",
+			wantErr: "invalid default for PORT (Config.Port int)",
+		},
+		{
+			name:   "reference without default",
+			fields: "Base int\nPort int `default:\"${BASE}0\"`",
+		},
+		{
+			name:    "nested",
+			fields:  "Db DbConfig\n}\n\ntype DbConfig struct {\nPort int `default:\"abc\"`",
+			wantErr: "invalid default for DB_PORT (DbConfig.Port int)",
+		},
+		{
+			name:   "checks",
+			fields: "Port int `default:\"80\" min:\"1\" max:\"65535\"`\nWait time.Duration `default:\"1m\" max:\"1h\"`\nName string `default:\"api\" minlen:\"2\" maxlen:\"8\" pattern:\"^[a-z]+$\"`\nHosts []string `default:\"a\" nonempty:\"\"`",
+		},
+		{
+			name:    "min",
+			fields:  "Port int `default:\"0\" min:\"1\"`",
+			wantErr: "invalid default for PORT (Config.Port int): validation failed: PORT does not satisfy min=1",
+		},
+		{
+			name:    "max duration",
+			fields:  "Wait time.Duration `default:\"2h\" max:\"1h\"`",
+			wantErr: "invalid default for WAIT (Config.Wait time.Duration): validation failed: WAIT does not satisfy max=1h",
+		},
+		{
+			name:    "minlen",
+			fields:  "Name string `default:\"a\" minlen:\"2\"`",
+			wantErr: "NAME does not satisfy minlen=2",
+		},
+		{
+			name:    "maxlen",
+			fields:  "Name string `default:\"abc\" maxlen:\"2\"`",
+			wantErr: "NAME does not satisfy maxlen=2",
+		},
+		{
+			name:    "pattern",
+			fields:  "Name string `default:\"API\" pattern:\"^[a-z]+$\"`",
+			wantErr: "NAME does not satisfy pattern=^[a-z]+$",
+		},
+		{
+			name:    "nonempty",
+			fields:  "Hosts []string `default:\"\" nonempty:\"\"`",
+			wantErr: "HOSTS does not satisfy nonempty",
+		},
+		{
+			name:    "expanded check",
+			fields:  "Base int `default:\"0\"`\nPort int `default:\"${BASE}\" min:\"1\"`",
+			wantErr: "PORT does not satisfy min=1",
+		},
+		{
+			name:    "prefix",
+			fields:  "Port int `default:\"abc\"`",
+			cfg:     GenConfig{Prefix: "APP"},
+			wantErr: "invalid default for APP_PORT (Config.Port int)",
+		},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			// time is always used so cases without a duration still build
+			src := "package main\n\nimport \"time\"\n\nvar _ time.Duration\n\ntype Config struct {\n" + tc.fields + "\n}\n"
+			_, err := generate(t, src, tc.cfg)
+			if tc.wantErr == "" {
+				if err != nil {
+					t.Fatal(err)
+				}
+
+				return
+			}
+
+			if err == nil || !strings.Contains(err.Error(), tc.wantErr) {
+				t.Fatalf("expected %q, got %v", tc.wantErr, err)
+			}
+		})
+	}
+}
+
+func TestGeneratedDefaultConfig(t *testing.T) {
+	src := `package main
+
+import "errors"
+
+type Config struct {
+	Host string `+"`default:\"localhost\"`"+`
+	Port int    `+"`default:\"80\" min:\"1\"`"+`
+	Name string `+"`minlen:\"2\"`"+`
+	Db   DbConfig
+	Log  *LogConfig
+}
+
+type DbConfig struct {
+	File  string   `+"`default:\"db.sqlite\"`"+`
+	User  string
+	Hosts []string `+"`default:\"a, b\"`"+`
+}
+
+type LogConfig struct {
+	Level string `+"`default:\"info\"`"+`
+}
+
+func (c *Config) Validate() error {
+	return errors.New("hooks are not run on defaults")
+}
+`
+	mainSrc := `package main
+
+import (
+	"fmt"
+)
+
+func main() {
+	// required keys are left empty and rules and hooks are not run
+	c, err := DefaultConfig()
+	fmt.Println(c.Host, c.Port, c.Name == "", c.Db.File, c.Db.User == "", c.Db.Hosts, c.Log.Level, err)
+	fmt.Println(len(ConfigDefaults()))
+}
+`
+
+	for _, cfg := range []GenConfig{{}, {Aggregate: true}, {Runtime: true}} {
+		got := runGenerated(t, src, cfg, mainSrc)
+		want := "localhost 80 true db.sqlite true [a b] info <nil>\n5"
+		if got != want {
+			t.Fatalf("%+v got:\n%v\nwant:\n%v", cfg, got, want)
+		}
+	}
+
+	// references to keys without a default can only fail once built
+	got := runGenerated(t, `package main
+
+type Config struct {
+	Base int
+	Port int `+"`default:\"${BASE}0\"`"+`
+}
+`, GenConfig{}, `package main
+
+import (
+	"errors"
+	"fmt"
+)
+
+func main() {
+	c, err := DefaultConfig()
+	fmt.Println(c.Port, errors.Is(err, ErrUnresolvedRef))
+}
+`)
+	if got != "0 true" {
+		t.Fatalf("got %v", got)
+	}
+}
@@ -0,0 +1,164 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"strings"
+)
+
+// diffKeyExpr is keyExpr for Diff, where nested types may be compared
+// without a prefix.
+func (f *Field) diffKeyExpr() string {
+	if f.rootTypeField {
+		return f.keyExpr()
+	}
+
+	return fmt.Sprintf("changeKey(prefix, %q)", f.envKey)
+}
+
+// writeClone writes the Clone method of our type copying every slice and
+// nested config, so changing the clone never changes c.
+func (b *StructBuilder) writeClone(w io.Writer) {
+	writeF(
+		w,
+		"// Clone returns a deep copy of c.\nfunc (c *%[1]v) Clone() *%[1]v {\nif c == nil {\nreturn nil\n}\n\nclone := *c\n",
+		b.name,
+	)
+
+	for _, f := range b.order {
+		switch {
+		case f.customType && f.pointer:
+			writeF(w, "clone.%[1]v = c.%[1]v.Clone()\n", f.goPath)
+		case f.customType:
+			writeF(w, "clone.%[1]v = *c.%[1]v.Clone()\n", f.goPath)
+		case f.slice:
+			b.importCache.Add("slices", "slices")
+			writeF(w, "clone.%[1]v = slices.Clone(c.%[1]v)\n", f.goPath)
+		}
+	}
+
+	writeF(w, "return &clone\n}\n\n")
+}
+
+// writeEqual writes the Equal method of our type comparing every field
+// we load, including nested configs.
+func (b *StructBuilder) writeEqual(w io.Writer) {
+	writeF(
+		w,
+		"// Equal reports whether c and other hold the same values.\nfunc (c *%[1]v) Equal(other *%[1]v) bool {\nif c == nil || other == nil {\nreturn c == other\n}\n\n",
+		b.name,
+	)
+
+	conds := make([]string, 0, len(b.order))
+	for _, f := range b.order {
+		switch {
+		case f.customType && f.pointer:
+			conds = append(conds, fmt.Sprintf("c.%[1]v.Equal(other.%[1]v)", f.goPath))
+		case f.customType:
+			conds = append(conds, fmt.Sprintf("c.%[1]v.Equal(&other.%[1]v)", f.goPath))
+		case f.slice:
+			b.importCache.Add("slices", "slices")
+			conds = append(conds, fmt.Sprintf("slices.Equal(c.%[1]v, other.%[1]v)", f.goPath))
+		default:
+			conds = append(conds, fmt.Sprintf("c.%[1]v == other.%[1]v", f.goPath))
+		}
+	}
+
+	if len(conds) == 0 {
+		conds = append(conds, "true")
+	}
+
+	writeF(w, "return %v\n}\n\n", strings.Join(conds, " &&\n"))
+}
+
+// writeDiff writes the Diff method of our type and the func it uses to
+// list the changed keys.
+func (b *StructBuilder) writeDiff(w io.Writer) error {
+	b.templates.Use("Change", "ChangeKey")
+
+	if b.rootType {
+		writeF(
+			w,
+			`// Diff lists every key whose value differs in other, with secret
+			// values redacted.
+			func (c *%[1]v) Diff(other *%[1]v) []Change {
+				return diff%[1]v(c, other)
+			}
+
+			func diff%[1]v(a, b *%[1]v) []Change {
+			`,
+			b.name,
+		)
+	} else {
+		writeF(
+			w,
+			`// Diff lists every key whose value differs in other, with secret
+			// values redacted. Keys are not prefixed, as %[1]v does not
+			// know the prefix it was loaded with.
+			func (c *%[1]v) Diff(other *%[1]v) []Change {
+				return diff%[1]v(c, other, "")
+			}
+
+			func diff%[1]v(a, b *%[1]v, prefix string) []Change {
+			`,
+			b.name,
+		)
+	}
+
+	// a missing nested config is compared as if it was empty, such as a
+	// build type option that was not selected
+	writeF(
+		w,
+		"if a == nil && b == nil {\nreturn nil\n}\n\nif a == nil {\na = &%[1]v{}\n}\n\nif b == nil {\nb = &%[1]v{}\n}\n\nvar changes []Change\n",
+		b.name,
+	)
+
+	for _, f := range b.order {
+		key := f.diffKeyExpr()
+		switch {
+		case f.customType && f.pointer:
+			writeF(w, "changes = append(changes, diff%[2]v(a.%[1]v, b.%[1]v, %[3]v)...)\n", f.goPath, f.typeName, key)
+		case f.customType:
+			writeF(w, "changes = append(changes, diff%[2]v(&a.%[1]v, &b.%[1]v, %[3]v)...)\n", f.goPath, f.typeName, key)
+		case f.secret:
+			b.templates.Use("Redact")
+			changed := fmt.Sprintf("a.%[1]v != b.%[1]v", f.goPath)
+			if f.slice {
+				b.importCache.Add("slices", "slices")
+				changed = fmt.Sprintf("!slices.Equal(a.%[1]v, b.%[1]v)", f.goPath)
+			}
+
+			aSet, _ := f.setExprOf("a")
+			bSet, _ := f.setExprOf("b")
+			writeF(
+				w,
+				"if %v {\nchanges = append(changes, Change{Key: %v, Old: redact(%v), New: redact(%v)})\n}\n",
+				changed,
+				key,
+				aSet,
+				bSet,
+			)
+		default:
+			if _, found := convMap[f.typeName]; !found {
+				return fmt.Errorf("unknown type: %v", f.typeName)
+			}
+
+			format := "%[2]v(a.%[1]v), %[2]v(b.%[1]v)"
+			if f.slice {
+				b.templates.Use("FormatSlice")
+				format = "formatSlice(a.%[1]v, %[2]v), formatSlice(b.%[1]v, %[2]v)"
+			}
+
+			writeF(
+				w,
+				"if av, bv := "+format+"; av != bv {\nchanges = append(changes, Change{Key: %[3]v, Old: av, New: bv})\n}\n",
+				f.goPath,
+				f.formatFunc(),
+				key,
+			)
+		}
+	}
+
+	writeF(w, "return changes\n}\n\n")
+	return nil
+}
@@ -0,0 +1,84 @@
+package main
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestCompareOptIn(t *testing.T) {
+	generated := mustGenerate(t, "package main\n\ntype Config struct {\nPorts []int `default:\"80\"`\n}\n", GenConfig{})
+	hasNone(t, generated, ") Clone()", ") Equal(", ") Diff(", "type Change struct")
+}
+
+func TestGeneratedCompare(t *testing.T) {
+	got := runGenerated(t, `package main
+
+type Config struct {
+	Host     string   `+"`default:\"localhost\"`"+`
+	Ports    []int    `+"`default:\"80,443\"`"+`
+	Password string   `+"`default:\"pw\" secret:\"true\"`"+`
+	Db       *DbConfig
+	Log      LogConfig
+}
+
+type DbConfig struct {
+	File string `+"`default:\"db.sqlite\"`"+`
+}
+
+type LogConfig struct {
+	Level string `+"`default:\"info\"`"+`
+}
+`, GenConfig{With: []string{"compare"}}, `package main
+
+import (
+	"fmt"
+)
+
+func main() {
+	a, err := DefaultConfig()
+	if err != nil {
+		panic(err)
+	}
+
+	// clones share nothing with the original
+	b := a.Clone()
+	fmt.Println(a.Equal(b), b.Db != a.Db)
+
+	b.Ports[0] = 8080
+	b.Password = "other"
+	b.Db.File = "other.sqlite"
+	b.Log.Level = "debug"
+	fmt.Println(a.Equal(b), a.Ports, a.Db.File)
+
+	// slices are compared as env values and secrets are redacted
+	for _, c := range a.Diff(b) {
+		fmt.Printf("%v %q %q\n", c.Key, c.Old, c.New)
+	}
+
+	// a missing nested config compares as an empty one, and nested
+	// configs are diffed alone without a key
+	b = a.Clone()
+	b.Db = nil
+	fmt.Println(a.Diff(b))
+	fmt.Println(a.Db.Diff(&DbConfig{File: "x"}))
+
+	var none *Config
+	fmt.Println(none.Clone() == nil, none.Equal(nil), none.Equal(a))
+}
+`)
+
+	want := strings.Join([]string{
+		"true true",
+		"false [80 443] db.sqlite",
+		`PORTS "80,443" "8080,443"`,
+		`PASSWORD "[REDACTED]" "[REDACTED]"`,
+		`DB_FILE "db.sqlite" "other.sqlite"`,
+		`LOG_LEVEL "info" "debug"`,
+		"[{DB_FILE db.sqlite }]",
+		"[{FILE db.sqlite x}]",
+		"true true false",
+	}, "\n")
+	if got != want {
+		t.Fatalf("got:\n%v\nwant:\n%v", got, want)
+	}
+}
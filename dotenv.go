@@ -0,0 +1,32 @@
+package main
+
+import (
+	"io"
+)
+
+// writeFromFiles writes the constructor loading our root config from
+// dotenv files layered under the real environment.
+func (b *StructBuilder) writeFromFiles(w io.Writer) {
+	if !b.rootType {
+		return
+	}
+
+	b.templates.Use("LoadDotenv", "DotenvCascade", "ChainLookup", "OSLookup")
+	writeF(
+		w,
+		`// New%[1]vFromFiles loads %[1]v from the environment falling back to the
+		// dotenv files, later files override earlier ones and the environment
+		// overrides them all.
+		func New%[1]vFromFiles(paths ...string) (*%[1]v, error) {
+			files, err := LoadDotenv(paths...)
+			if err != nil {
+				return nil, err
+			}
+
+			return New%[1]vFrom(ChainLookup{OSLookup, files})
+		}
+
+		`,
+		b.name,
+	)
+}
@@ -0,0 +1,121 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"strings"
+)
+
+// EnvExample writes an example env file documenting every key loaded by
+// our root configs, nested types are written with their full prefix.
+type EnvExample struct {
+	builders map[string]*StructBuilder
+}
+
+func (e *EnvExample) Write(w io.Writer, rootTypes []string) error {
+	err := writeF(
+		w,
+		"# Example set of configurations as defined by %v\n# This file is auto-generated by genenv\n",
+		strings.Join(rootTypes, ", "),
+	)
+	if err != nil {
+		return err
+	}
+
+	for _, rootType := range rootTypes {
+		// separate each root when there is more than one
+		if len(rootTypes) > 1 {
+			writeF(w, "\n# %v\n", rootType)
+		}
+
+		b := e.builders[rootType]
+		if err := e.writeStruct(w, b, b.keyPrefix, ""); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+func (e *EnvExample) writeStruct(w io.Writer, b *StructBuilder, prefix, loadIf string) error {
+	typeField, hasTypeField := b.fields["Type"]
+
+	for _, f := range b.order {
+		key := joinKey(prefix, f.envKey)
+		fieldLoadIf := loadIf
+		if hasTypeField && f != typeField {
+			fieldLoadIf = fmt.Sprintf("%v=%v", joinKey(prefix, typeField.envKey), f.envKey)
+		}
+
+		writeF(w, "\n")
+		writeComment(w, f.docs)
+
+		if f.deprecated {
+			writeF(w, "# DEPRECATED\n")
+		}
+
+		if f.customType {
+			nested, found := e.builders[f.typeName]
+			if !found {
+				return fmt.Errorf("nested type '%v' was not built", f.typeName)
+			}
+
+			writeF(w, "# See: %v\n", f.typeName)
+			writeComment(w, nested.us.Doc)
+			if err := e.writeStruct(w, nested, key, fieldLoadIf); err != nil {
+				return err
+			}
+
+			continue
+		}
+
+		if fieldLoadIf != "" {
+			writeF(w, "# Only used when %v\n", fieldLoadIf)
+		}
+
+		if hasTypeField && f == typeField {
+			writeF(w, "# Allowed values: %v\n", strings.Join(buildTypeValues(b), ", "))
+		}
+
+		if len(f.aliases) > 0 {
+			writeF(w, "# Deprecated aliases: %v\n", strings.Join(prefixAll(prefix, f.aliases), ", "))
+		}
+
+		if f.noReload {
+			writeF(w, "# Requires a restart to change\n")
+		}
+
+		// secret defaults are left out so they are not copied around
+		value := f.defaultValue
+		if f.secret {
+			value = ""
+			writeF(w, "# Secret\n")
+		}
+
+		if f.required {
+			writeF(w, "# Required\n")
+		} else if f.hasDefault && value != "" {
+			writeF(w, "# Default: %v\n", value)
+		}
+
+		// deprecated keys are left commented out so they are not copied
+		if f.deprecated {
+			writeF(w, "# ")
+		}
+
+		writeF(w, "%v=%v\n", key, value)
+	}
+
+	return nil
+}
+
+func writeComment(w io.Writer, text string) {
+	text = strings.TrimSpace(text)
+	if text == "" {
+		return
+	}
+
+	for _, line := range strings.Split(text, "\n") {
+		writeF(w, "%v\n", strings.TrimSpace("# "+line))
+	}
+}
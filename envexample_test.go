@@ -0,0 +1,76 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestEnvExample(t *testing.T) {
+	dir := t.TempDir()
+	writeSource(t, dir, "config.go", `package main
+
+type Config struct {
+	// Host to listen on.
+	Host string `+"`default:\"localhost\" reload:\"false\"`"+`
+	// Token to authenticate with.
+	Token string `+"`default:\"hunter2\"`"+`
+	// Level of logs.
+	Level string `+"`default:\"info\" aliases:\"LOGGING_LEVEL\"`"+`
+	// Verbose logs everything.
+	//
+	// Deprecated: use LEVEL instead.
+	Verbose bool `+"`default:\"false\"`"+`
+	Db      *DbConfig
+}
+
+// DbConfig of the store.
+type DbConfig struct {
+	File string
+}
+`)
+
+	envFile := filepath.Join(dir, ".env.example")
+	if _, err := generateIn(t, dir, GenConfig{Prefix: "APP", EnvOutputFile: envFile}); err != nil {
+		t.Fatal(err)
+	}
+
+	example, err := os.ReadFile(envFile)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	want := `# Example set of configurations as defined by Config
+# This file is auto-generated by genenv
+
+# Host to listen on.
+# Requires a restart to change
+# Default: localhost
+APP_HOST=localhost
+
+# Token to authenticate with.
+# Secret
+APP_TOKEN=
+
+# Level of logs.
+# Deprecated aliases: APP_LOGGING_LEVEL
+# Default: info
+APP_LEVEL=info
+
+# Verbose logs everything.
+#
+# Deprecated: use LEVEL instead.
+# DEPRECATED
+# Default: false
+# APP_VERBOSE=false
+
+# See: DbConfig
+# DbConfig of the store.
+
+# Required
+APP_DB_FILE=
+`
+	if string(example) != want {
+		t.Fatalf("got:\n%v\nwant:\n%v", string(example), want)
+	}
+}
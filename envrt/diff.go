@@ -0,0 +1,19 @@
+package envrt
+
+// Change is a key whose value differs between two configs, values are
+// formatted as env values with secrets redacted.
+type Change struct {
+	Key string
+	Old string
+	New string
+}
+
+// ChangeKey joins a key to the prefix of a nested config, the prefix is
+// empty when diffing a nested config on its own.
+func ChangeKey(prefix, key string) string {
+	if prefix == "" {
+		return key
+	}
+
+	return prefix + "_" + key
+}
@@ -0,0 +1,10 @@
+// Package envrt is the runtime shared by configs generated with the
+// --runtime option of genenv.
+//
+// Without the option every generated file carries its own copy of these
+// helpers, written from the source of this package, which keeps it free
+// of dependencies but means two configs in one package declare the same
+// symbols. Generating against envrt instead
+// lets any number of configs share a single copy, and fixes to parsing
+// only need a version bump rather than regenerating every config.
+package envrt
@@ -0,0 +1,166 @@
+package envrt
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+)
+
+var (
+	ErrKeyNotFound      = errors.New("env var key not found")
+	ErrInvalidBool      = errors.New("invalid bool value")
+	ErrValidation       = errors.New("validation failed")
+	ErrConflictingKeys  = errors.New("conflicting values for env var")
+	ErrUnresolvedRef    = errors.New("referenced env var not found")
+	ErrRefCycle         = errors.New("env var references form a cycle")
+	ErrUnclosedRef      = errors.New("unclosed env var reference")
+	ErrUnknownKey       = errors.New("unknown env var")
+	ErrDotenvSyntax     = errors.New("invalid dotenv syntax")
+	ErrReloadFixed      = errors.New("field can not change on reload")
+	ErrInvalidBuildType = errors.New("invalid build type")
+)
+
+// FieldError wraps the failure of a single field with the details needed
+// to fix it. Values of secret fields are redacted. Rules and Validate
+// hooks failing after loading name the fields or type they belong to.
+type FieldError struct {
+	Key     string
+	Value   string
+	GoField string
+	Type    string
+	Doc     string
+	Secret  bool
+	Err     error
+}
+
+func (e *FieldError) Error() string {
+	msg := e.Err.Error()
+	if e.Secret && e.Value != "" {
+		msg = secretCause(e.Err, e.Value)
+	}
+
+	// roots without a prefix validate under an empty key
+	if e.Key != "" {
+		msg = fmt.Sprintf("%v: %v", e.Key, msg)
+	}
+
+	if e.GoField != "" {
+		msg += fmt.Sprintf(" (%v)", strings.TrimSpace(e.GoField+" "+e.Type))
+	}
+
+	if hint, _, _ := strings.Cut(e.Doc, "\n"); hint != "" {
+		msg += ": " + hint
+	}
+
+	return msg
+}
+
+func (e *FieldError) Unwrap() error {
+	return e.Err
+}
+
+// secretCause describes err without its text, which may include the value
+// of a secret in any form such as quoted by strconv. Validation errors
+// only name the key and constraint so are kept.
+func secretCause(err error, redacted string) string {
+	var ve *ValidationError
+	if errors.As(err, &ve) {
+		return ve.Error()
+	}
+
+	return "invalid value " + redacted
+}
+
+// DescribeField adds the go field details to a field error.
+func DescribeField(err error, goField, typ, doc string, secret bool) error {
+	var fe *FieldError
+	if !errors.As(err, &fe) {
+		return err
+	}
+
+	fe.GoField = goField
+	fe.Type = typ
+	fe.Doc = doc
+	fe.Secret = secret
+	if secret && fe.Value != "" {
+		fe.Value = Redact(true)
+	}
+
+	return fe
+}
+
+// ValidationError is returned when a value does not satisfy one of
+// the validation tags of its field.
+type ValidationError struct {
+	Key        string
+	Constraint string
+}
+
+func (e *ValidationError) Error() string {
+	return fmt.Sprintf("%v: %v does not satisfy %v", ErrValidation, e.Key, e.Constraint)
+}
+
+func (e *ValidationError) Unwrap() error {
+	return ErrValidation
+}
+
+// ConfigErrors holds every error found while loading a config,
+// nested configs are flattened into the same list.
+type ConfigErrors []error
+
+func (e ConfigErrors) Error() string {
+	var sb strings.Builder
+	fmt.Fprintf(&sb, "config has %v error(s):", len(e))
+	for _, err := range e {
+		fmt.Fprintf(&sb, "\n- [ ] %v", err)
+	}
+
+	return sb.String()
+}
+
+func (e ConfigErrors) Unwrap() []error {
+	return e
+}
+
+func (e ConfigErrors) Is(target error) bool {
+	for _, err := range e {
+		if errors.Is(err, target) {
+			return true
+		}
+	}
+
+	return false
+}
+
+func (e ConfigErrors) As(target any) bool {
+	for _, err := range e {
+		if errors.As(err, target) {
+			return true
+		}
+	}
+
+	return false
+}
+
+// Err returns nil if no errors were found or the errors otherwise.
+func (e ConfigErrors) Err() error {
+	if len(e) == 0 {
+		return nil
+	}
+
+	return e
+}
+
+func (e *ConfigErrors) Add(err error) {
+	if err == nil {
+		return
+	}
+
+	var nested ConfigErrors
+	if errors.As(err, &nested) {
+		*e = append(*e, nested...)
+		return
+	}
+
+	*e = append(*e, err)
+}
@@ -0,0 +1,155 @@
+package envrt
+
+import (
+	"errors"
+	"strconv"
+	"testing"
+)
+
+func TestFieldErrorSecret(t *testing.T) {
+	for _, tc := range []struct {
+		name    string
+		value   string
+		conv    func(string) (any, error)
+		secret  bool
+		want    string
+		wantErr error
+	}{
+		{
+			name:    "plain",
+			value:   "hunter2",
+			conv:    anyConv(ConvInt),
+			want:    `PORT: strconv.ParseInt: parsing "hunter2": invalid syntax (Config.Port int): the port`,
+			wantErr: strconv.ErrSyntax,
+		},
+		{
+			name:    "quoted by the cause",
+			value:   "hunter2",
+			conv:    anyConv(ConvInt),
+			secret:  true,
+			want:    "PORT: invalid value [REDACTED] (Config.Port int): the port",
+			wantErr: strconv.ErrSyntax,
+		},
+		{
+			name:    "short secret",
+			value:   "a",
+			conv:    anyConv(ConvBool),
+			secret:  true,
+			want:    "PORT: invalid value [REDACTED] (Config.Port int): the port",
+			wantErr: ErrInvalidBool,
+		},
+		{
+			name:    "unclosed ref",
+			value:   "hunter${2",
+			conv:    anyConv(ConvString),
+			secret:  true,
+			want:    "PORT: invalid value [REDACTED] (Config.Port int): the port",
+			wantErr: ErrUnclosedRef,
+		},
+		{
+			name:    "missing",
+			secret:  true,
+			conv:    anyConv(ConvInt),
+			want:    "PORT: env var key not found (Config.Port int): the port",
+			wantErr: ErrKeyNotFound,
+		},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			env := MapLookup{}
+			if tc.value != "" {
+				env["PORT"] = tc.value
+			}
+
+			ld := &Loader{Lookup: env, Defaults: MapLookup{}}
+			_, err := ParseRequired(ld, "PORT", tc.conv)
+			err = DescribeField(err, "Config.Port", "int", "the port", tc.secret)
+
+			if got := err.Error(); got != tc.want {
+				t.Fatalf("got %q, want %q", got, tc.want)
+			}
+
+			if !errors.Is(err, tc.wantErr) {
+				t.Fatalf("expected %v to wrap %v", err, tc.wantErr)
+			}
+		})
+	}
+}
+
+func TestFieldErrorSecretValidation(t *testing.T) {
+	err := DescribeField(&FieldError{
+		Key:   "TOKEN",
+		Value: "abc",
+		Err:   &ValidationError{Key: "TOKEN", Constraint: "minlen=8"},
+	}, "Config.Token", "string", "", true)
+
+	want := "TOKEN: validation failed: TOKEN does not satisfy minlen=8 (Config.Token string)"
+	if got := err.Error(); got != want {
+		t.Fatalf("got %q, want %q", got, want)
+	}
+}
+
+func TestFieldErrorAfterLoading(t *testing.T) {
+	hookErr := errors.New("trace is not allowed")
+
+	for _, tc := range []struct {
+		name string
+		err  *FieldError
+		want string
+	}{
+		{
+			name: "group rule",
+			err: &FieldError{
+				Key:     "FILE, URL",
+				GoField: "Config.File, Config.Url",
+				Err:     &ValidationError{Key: "FILE, URL", Constraint: "at most one set"},
+			},
+			want: "FILE, URL: validation failed: FILE, URL does not satisfy at most one set (Config.File, Config.Url)",
+		},
+		{
+			name: "hook of a root without a prefix",
+			err:  &FieldError{GoField: "Config", Doc: "Config of the app.", Err: hookErr},
+			want: "trace is not allowed (Config): Config of the app.",
+		},
+		{
+			name: "hook of a nested type",
+			err:  &FieldError{Key: "APP_DB", GoField: "DbConfig", Err: hookErr},
+			want: "APP_DB: trace is not allowed (DbConfig)",
+		},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			if got := tc.err.Error(); got != tc.want {
+				t.Fatalf("got %q, want %q", got, tc.want)
+			}
+		})
+	}
+}
+
+func TestConfigErrors(t *testing.T) {
+	var errs ConfigErrors
+	if errs.Err() != nil {
+		t.Fatal("expected no error without any added")
+	}
+
+	errs.Add(nil)
+	errs.Add(&FieldError{Key: "PORT", Err: ErrKeyNotFound})
+
+	// nested configs are flattened into the same list
+	var nested ConfigErrors
+	nested.Add(&ValidationError{Key: "DB_FILE", Constraint: "minlen=2"})
+	errs.Add(nested.Err())
+
+	err := errs.Err()
+	if len(errs) != 2 || !errors.Is(err, ErrKeyNotFound) || !errors.Is(err, ErrValidation) {
+		t.Fatalf("expected both errors, got %v", err)
+	}
+
+	var ve *ValidationError
+	if !errors.As(err, &ve) || ve.Key != "DB_FILE" {
+		t.Fatalf("expected the validation error, got %v", ve)
+	}
+
+	want := "config has 2 error(s):\n- [ ] " + errs[0].Error() + "\n- [ ] " + errs[1].Error()
+	if err.Error() != want {
+		t.Fatalf("got:\n%v\nwant:\n%v", err, want)
+	}
+}
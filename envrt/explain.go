@@ -0,0 +1,72 @@
+package envrt
+
+import (
+	"fmt"
+	"strings"
+	"text/tabwriter"
+)
+
+// FieldSource is where the value of a single key was loaded from.
+type FieldSource struct {
+	Key string
+	// GoField is the path of the field from the root config
+	GoField string
+	// Value is the value before parsing with references expanded,
+	// redacted for secrets
+	Value string
+	// Source is "default", "unset", "not loaded" or the source named by
+	// the Lookuper such as "env", "file .env" or "flag --port"
+	Source string
+	// Alias is the deprecated key the value was found under, if any
+	Alias  string
+	Secret bool
+}
+
+// FieldSources lists the source of every key of a config.
+type FieldSources []FieldSource
+
+// Explain lists the source of every key in schema from the sources
+// recorded while loading. Keys never loaded, such as those of a build
+// type that was not selected, are "not loaded".
+func Explain(schema []KeySchema, recorded FieldSources) FieldSources {
+	byKey := make(map[string]FieldSource, len(recorded))
+	for _, fs := range recorded {
+		byKey[fs.Key] = fs
+	}
+
+	sources := make(FieldSources, len(schema))
+	for i, ks := range schema {
+		fs, found := byKey[ks.Key]
+		if !found {
+			fs = FieldSource{Key: ks.Key, Source: "not loaded"}
+		}
+
+		fs.GoField = ks.GoPath
+		fs.Secret = ks.Secret
+		if ks.Secret {
+			fs.Value = Redact(fs.Value != "")
+		}
+
+		sources[i] = fs
+	}
+
+	return sources
+}
+
+// String formats the sources as a table.
+func (s FieldSources) String() string {
+	var sb strings.Builder
+	tw := tabwriter.NewWriter(&sb, 0, 4, 2, ' ', 0)
+	fmt.Fprintln(tw, "KEY\tVALUE\tSOURCE")
+	for _, fs := range s {
+		source := fs.Source
+		if fs.Alias != "" {
+			source += " (alias " + fs.Alias + ")"
+		}
+
+		fmt.Fprintf(tw, "%v\t%v\t%v\n", fs.Key, fs.Value, source)
+	}
+	tw.Flush()
+
+	return sb.String()
+}
@@ -0,0 +1,54 @@
+package envrt
+
+import (
+	"slices"
+	"testing"
+)
+
+func TestLoaderSources(t *testing.T) {
+	ld := &Loader{
+		Lookup:   NamedLookup{Name: "file .env", Lookuper: MapLookup{"OLD_PORT": "8080"}},
+		Defaults: MapLookup{"HOST": "localhost", "ADDR": "${HOST}:80"},
+	}
+
+	for _, key := range []string{"HOST", "ADDR", "NAME"} {
+		if _, err := ParseOptional(ld, key, ConvString); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	if _, err := ParseRequired(ld, "PORT", ConvInt, "OLD_PORT"); err != nil {
+		t.Fatal(err)
+	}
+
+	want := FieldSources{
+		{Key: "HOST", Value: "localhost", Source: "default"},
+		{Key: "ADDR", Value: "localhost:80", Source: "default"},
+		{Key: "NAME", Source: "unset"},
+		{Key: "PORT", Value: "8080", Source: "file .env", Alias: "OLD_PORT"},
+	}
+	if !slices.Equal(ld.Sources, want) {
+		t.Errorf("got %+v\nwant %+v", ld.Sources, want)
+	}
+}
+
+func TestExplain(t *testing.T) {
+	schema := []KeySchema{
+		{Key: "PORT", GoPath: "Port"},
+		{Key: "TOKEN", GoPath: "Token", Secret: true},
+		{Key: "DB_FILE", GoPath: "Db.File"},
+	}
+	recorded := FieldSources{
+		{Key: "TOKEN", Value: "hunter2", Source: "env"},
+		{Key: "PORT", Value: "8080", Source: "env"},
+	}
+
+	want := FieldSources{
+		{Key: "PORT", GoField: "Port", Value: "8080", Source: "env"},
+		{Key: "TOKEN", GoField: "Token", Value: "[REDACTED]", Source: "env", Secret: true},
+		{Key: "DB_FILE", GoField: "Db.File", Source: "not loaded"},
+	}
+	if got := Explain(schema, recorded); !slices.Equal(got, want) {
+		t.Errorf("got %+v\nwant %+v", got, want)
+	}
+}
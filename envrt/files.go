@@ -0,0 +1,268 @@
+package envrt
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+)
+
+// ParseDotenv reads key=value pairs in the dotenv format. Values may be
+// single or double quoted, span lines when quoted and double quoted
+// values support escapes. Lines may start with export and comments start
+// with #.
+func ParseDotenv(r io.Reader) (MapLookup, error) {
+	data, err := io.ReadAll(r)
+	if err != nil {
+		return nil, err
+	}
+
+	values := MapLookup{}
+	lines := strings.Split(strings.ReplaceAll(string(data), "\r\n", "\n"), "\n")
+
+	for i := 0; i < len(lines); i++ {
+		lineNum := i + 1
+		// only the start of the line is trimmed, the end may be part of
+		// a quoted value spanning lines
+		line := strings.TrimLeft(lines[i], " \t")
+		if strings.TrimSpace(line) == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+
+		if rest := strings.TrimPrefix(line, "export"); rest != line && strings.IndexAny(rest, " \t") == 0 {
+			line = strings.TrimLeft(rest, " \t")
+		}
+
+		key, value, found := strings.Cut(line, "=")
+		key = strings.TrimSpace(key)
+		if !found || key == "" || strings.ContainsAny(key, " \t") {
+			return nil, fmt.Errorf("%w: line %v: expected key=value", ErrDotenvSyntax, lineNum)
+		}
+
+		value = strings.TrimLeft(value, " \t")
+		if value == "" || (value[0] != '"' && value[0] != '\'') {
+			value = strings.TrimSpace(value)
+			// unquoted values end at an inline comment
+			if idx := strings.Index(value, " #"); idx >= 0 {
+				value = strings.TrimSpace(value[:idx])
+			}
+
+			values[key] = value
+			continue
+		}
+
+		quote := value[0]
+		body := value[1:]
+		for {
+			end := closingQuote(body, quote)
+			if end >= 0 {
+				rest := strings.TrimSpace(body[end+1:])
+				if rest != "" && !strings.HasPrefix(rest, "#") {
+					return nil, fmt.Errorf("%w: line %v: unexpected text after quote", ErrDotenvSyntax, i+1)
+				}
+
+				body = body[:end]
+				break
+			}
+
+			i++
+			if i >= len(lines) {
+				return nil, fmt.Errorf("%w: line %v: unterminated quote", ErrDotenvSyntax, lineNum)
+			}
+
+			body += "\n" + lines[i]
+		}
+
+		if quote == '"' {
+			body = unescapeDotenv(body)
+		}
+
+		values[key] = body
+	}
+
+	return values, nil
+}
+
+// closingQuote finds the index of the quote ending s, double quotes can
+// be escaped with a backslash.
+func closingQuote(s string, quote byte) int {
+	for i := 0; i < len(s); i++ {
+		switch {
+		case s[i] == '\\' && quote == '"':
+			i++
+		case s[i] == quote:
+			return i
+		}
+	}
+
+	return -1
+}
+
+func unescapeDotenv(s string) string {
+	var sb strings.Builder
+	for i := 0; i < len(s); i++ {
+		if s[i] != '\\' || i+1 == len(s) {
+			sb.WriteByte(s[i])
+			continue
+		}
+
+		i++
+		switch s[i] {
+		case 'n':
+			sb.WriteByte('\n')
+		case 't':
+			sb.WriteByte('\t')
+		case 'r':
+			sb.WriteByte('\r')
+		case '"', '\\', '$':
+			sb.WriteByte(s[i])
+		default:
+			sb.WriteByte('\\')
+			sb.WriteByte(s[i])
+		}
+	}
+
+	return sb.String()
+}
+
+// LoadDotenv parses each file in order with later files overriding
+// earlier ones, each file is named as the source of its values. Missing
+// files are skipped so optional files such as .env.local can always be
+// listed.
+func LoadDotenv(paths ...string) (ChainLookup, error) {
+	var files ChainLookup
+	for _, path := range paths {
+		f, err := os.Open(path)
+		if errors.Is(err, os.ErrNotExist) {
+			continue
+		}
+
+		if err != nil {
+			return nil, err
+		}
+
+		fileValues, err := ParseDotenv(f)
+		f.Close()
+		if err != nil {
+			return nil, fmt.Errorf("%v: %w", path, err)
+		}
+
+		files = append(ChainLookup{NamedLookup{Name: "file " + path, Lookuper: fileValues}}, files...)
+	}
+
+	return files, nil
+}
+
+// DotenvCascade returns the usual dotenv files in order of precedence,
+// lowest first, with profile specific files when profile is not empty.
+func DotenvCascade(profile string) []string {
+	paths := []string{".env", ".env.local"}
+	if profile != "" {
+		paths = append(paths, ".env."+profile, ".env."+profile+".local")
+	}
+
+	return paths
+}
+
+// LoadJSONFile reads a JSON object of config values. Keys are matched to
+// env keys ignoring case and nested objects join their keys with an
+// underscore, so {"data_store": {"type": "MEM"}} sets DATA_STORE_TYPE.
+// Keys that end up the same are an error. Arrays are written the same as
+// slices in env values, so they are read back as the same values.
+func LoadJSONFile(path string) (MapLookup, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	var obj map[string]any
+	dec := json.NewDecoder(f)
+	dec.UseNumber()
+	if err := dec.Decode(&obj); err != nil {
+		return nil, fmt.Errorf("%v: %w", path, err)
+	}
+
+	values := MapLookup{}
+	if err := flattenJSON(values, "", obj); err != nil {
+		return nil, fmt.Errorf("%v: %w", path, err)
+	}
+
+	return values, nil
+}
+
+func flattenJSON(values MapLookup, prefix string, obj map[string]any) error {
+	// keys differing only by case or nesting flatten to the same key, as
+	// maps have no order one would win at random
+	set := func(key, value string) error {
+		if _, found := values[key]; found {
+			return fmt.Errorf("%w: %v is set by more than one JSON key", ErrConflictingKeys, key)
+		}
+
+		values[key] = value
+		return nil
+	}
+
+	for k, v := range obj {
+		key := strings.ToUpper(k)
+		if prefix != "" {
+			key = prefix + "_" + key
+		}
+
+		if nested, ok := v.(map[string]any); ok {
+			if err := flattenJSON(values, key, nested); err != nil {
+				return err
+			}
+
+			continue
+		}
+
+		if list, ok := v.([]any); ok {
+			parts := make([]string, 0, len(list))
+			for _, item := range list {
+				part, err := jsonValue(key, item)
+				if err != nil {
+					return err
+				}
+
+				parts = append(parts, part)
+			}
+
+			if err := set(key, FormatSlice(parts, FormatString)); err != nil {
+				return err
+			}
+
+			continue
+		}
+
+		if v == nil {
+			continue
+		}
+
+		value, err := jsonValue(key, v)
+		if err != nil {
+			return err
+		}
+
+		if err := set(key, value); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+func jsonValue(key string, v any) (string, error) {
+	switch tv := v.(type) {
+	case string:
+		return tv, nil
+	case json.Number:
+		return tv.String(), nil
+	case bool:
+		return fmt.Sprint(tv), nil
+	default:
+		return "", fmt.Errorf("unsupported JSON value for %v: %T", key, v)
+	}
+}
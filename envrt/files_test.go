@@ -0,0 +1,144 @@
+package envrt
+
+import (
+	"errors"
+	"maps"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"testing"
+)
+
+func TestParseDotenv(t *testing.T) {
+	for _, tc := range []struct {
+		name  string
+		input string
+		want  MapLookup
+	}{
+		{name: "empty", input: "", want: MapLookup{}},
+		{name: "plain", input: "A=1\nB=two", want: MapLookup{"A": "1", "B": "two"}},
+		{name: "spaces", input: "  A = 1  ", want: MapLookup{"A": "1"}},
+		{name: "empty value", input: "A=", want: MapLookup{"A": ""}},
+		{name: "comments", input: "# comment\nA=1 # inline\nB=a#b", want: MapLookup{"A": "1", "B": "a#b"}},
+		{name: "export", input: "export A=1\nexported=2", want: MapLookup{"A": "1", "exported": "2"}},
+		{name: "double quoted", input: `A="a # b"`, want: MapLookup{"A": "a # b"}},
+		{name: "single quoted", input: `A='a\nb'`, want: MapLookup{"A": `a\nb`}},
+		{name: "escapes", input: `A="a\nb\t\"c\" \\ \$"`, want: MapLookup{"A": "a\nb\t\"c\" \\ $"}},
+		{name: "crlf", input: "A=1\r\nB=2\r\n", want: MapLookup{"A": "1", "B": "2"}},
+		{name: "multi line", input: "A=\"one\ntwo\"\nB=3", want: MapLookup{"A": "one\ntwo", "B": "3"}},
+		{name: "multi line spaces", input: "A=\"  one  \n  two  \"", want: MapLookup{"A": "  one  \n  two  "}},
+		{name: "multi line single quoted", input: "A='one \n\\n two' # note", want: MapLookup{"A": "one \n\\n two"}},
+		{name: "multi line escapes", input: "A=\"one\\t\n\\\"two\\\"\"", want: MapLookup{"A": "one\t\n\"two\""}},
+		{name: "multi line comment", input: "A=\"one\n# not a comment\n\"", want: MapLookup{"A": "one\n# not a comment\n"}},
+		{name: "quoted spaces", input: `A="  padded  "  `, want: MapLookup{"A": "  padded  "}},
+		{name: "quoted empty", input: `A=""`, want: MapLookup{"A": ""}},
+		{name: "quoted equals", input: `A="b=c"`, want: MapLookup{"A": "b=c"}},
+		{name: "single quoted hash", input: `A='a # b' # comment`, want: MapLookup{"A": "a # b"}},
+		{name: "escaped quote", input: `A="say \"hi\""`, want: MapLookup{"A": `say "hi"`}},
+		{name: "unknown escape", input: `A="a\qb"`, want: MapLookup{"A": `a\qb`}},
+		{name: "indented", input: "\t  A=1", want: MapLookup{"A": "1"}},
+		{name: "export quoted", input: `export A="x y"`, want: MapLookup{"A": "x y"}},
+		{name: "export spaces", input: "export \t A=1", want: MapLookup{"A": "1"}},
+		{name: "comment after blank", input: "\n  # indented comment\nA=1", want: MapLookup{"A": "1"}},
+		{name: "later wins", input: "A=1\nA=2", want: MapLookup{"A": "2"}},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			got, err := ParseDotenv(strings.NewReader(tc.input))
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			if len(got) != len(tc.want) {
+				t.Fatalf("got %q, want %q", got, tc.want)
+			}
+
+			for k, v := range tc.want {
+				if got[k] != v {
+					t.Fatalf("%v: got %q, want %q", k, got[k], v)
+				}
+			}
+		})
+	}
+}
+
+func TestParseDotenvErrors(t *testing.T) {
+	for _, tc := range []struct {
+		name  string
+		input string
+	}{
+		{name: "no equals", input: "A"},
+		{name: "no key", input: "=1"},
+		{name: "space in key", input: "A B=1"},
+		{name: "unterminated", input: "A=\"one\ntwo"},
+		{name: "text after quote", input: `A="a" b`},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			if got, err := ParseDotenv(strings.NewReader(tc.input)); !errors.Is(err, ErrDotenvSyntax) {
+				t.Fatalf("expected ErrDotenvSyntax, got %q %v", got, err)
+			}
+		})
+	}
+}
+
+func TestLoadJSONFile(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "config.json")
+	err := os.WriteFile(path, []byte(`{
+	"host": "localhost",
+	"port": 80,
+	"tls": true,
+	"cert": null,
+	"data_store": {"type": "MEM", "sqlite": {"filename": "data.db"}},
+	"hosts": ["a,b", " c", "d\\e"]
+}`), 0o644)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	got, err := LoadJSONFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	want := MapLookup{
+		"HOST":                       "localhost",
+		"PORT":                       "80",
+		"TLS":                        "true",
+		"DATA_STORE_TYPE":            "MEM",
+		"DATA_STORE_SQLITE_FILENAME": "data.db",
+		"HOSTS":                      `a\,b,\ c,d\\e`,
+	}
+	if !maps.Equal(got, want) {
+		t.Fatalf("got %q, want %q", got, want)
+	}
+
+	// arrays read back the same as slices in env values
+	if hosts := SplitSlice(got["HOSTS"]); !slices.Equal(hosts, []string{"a,b", " c", `d\e`}) {
+		t.Fatalf("got %q", hosts)
+	}
+}
+
+func TestLoadJSONFileConflicts(t *testing.T) {
+	for _, tc := range []struct {
+		name string
+		json string
+	}{
+		{name: "nested and flat", json: `{"data_store": {"type": "MEM"}, "DATA_STORE_TYPE": "X"}`},
+		{name: "case", json: `{"port": 80, "PORT": 81}`},
+		{name: "array", json: `{"hosts": ["a"], "HOSTS": "b"}`},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			path := filepath.Join(t.TempDir(), "config.json")
+			if err := os.WriteFile(path, []byte(tc.json), 0o644); err != nil {
+				t.Fatal(err)
+			}
+
+			// map order is random, so every order has to fail the same
+			for i := 0; i < 10; i++ {
+				if got, err := LoadJSONFile(path); !errors.Is(err, ErrConflictingKeys) {
+					t.Fatalf("expected ErrConflictingKeys, got %q %v", got, err)
+				}
+			}
+		})
+	}
+}
@@ -0,0 +1,57 @@
+package envrt
+
+import (
+	"flag"
+	"strings"
+)
+
+// FlagAdder registers a single flag for an env key.
+type FlagAdder func(key, def, usage string, isBool bool)
+
+// FlagLookup finds env keys in flags, only flags set explicitly are
+// found so defaults and other sources are not hidden.
+type FlagLookup struct {
+	// Names maps each env key to its flag name
+	Names map[string]string
+	// Changed returns the value of a flag if it was set
+	Changed func(name string) (string, bool)
+}
+
+func (fl *FlagLookup) LookupEnv(key string) (string, bool) {
+	name, found := fl.Names[key]
+	if !found {
+		return "", false
+	}
+
+	return fl.Changed(name)
+}
+
+func (fl *FlagLookup) LookupSource(key string) (string, string, bool) {
+	v, ok := fl.LookupEnv(key)
+	return v, "flag --" + fl.Names[key], ok
+}
+
+// FlagUsage adds the env key to the usage of a flag.
+func FlagUsage(usage, key string) string {
+	if usage == "" {
+		return "env " + key
+	}
+
+	return usage + " (env " + key + ")"
+}
+
+// FlagName returns the flag name used for an env key.
+func FlagName(key string) string {
+	return strings.ToLower(strings.ReplaceAll(key, "_", "-"))
+}
+
+// StdFlagChanged checks if the standard library flag name was set, the
+// same as Changed of a pflag set.
+func StdFlagChanged(fs *flag.FlagSet, name string) bool {
+	set := false
+	fs.Visit(func(f *flag.Flag) {
+		set = set || f.Name == name
+	})
+
+	return set
+}
@@ -0,0 +1,95 @@
+package envrt
+
+import (
+	"bytes"
+	"encoding/json"
+	"html/template"
+	"net/http"
+	"strings"
+)
+
+// ConfigEntry is a single key served by a config handler.
+type ConfigEntry struct {
+	Key string
+	// Value is the loaded value formatted as an env value, redacted for
+	// secrets
+	Value   string
+	Source  string
+	Type    string
+	Default string
+	Secret  bool
+	Doc     string
+}
+
+// ConfigEntries joins the schema, loaded values and sources of a config.
+func ConfigEntries(schema []KeySchema, env map[string]string, sources FieldSources) []ConfigEntry {
+	sourceOf := make(map[string]string, len(sources))
+	for _, fs := range sources {
+		source := fs.Source
+		if fs.Alias != "" {
+			source += " (alias " + fs.Alias + ")"
+		}
+
+		sourceOf[fs.Key] = source
+	}
+
+	entries := make([]ConfigEntry, len(schema))
+	for i, ks := range schema {
+		v := env[ks.Key]
+		if ks.Secret {
+			v = Redact(v != "")
+		}
+
+		entries[i] = ConfigEntry{
+			Key:     ks.Key,
+			Value:   v,
+			Source:  sourceOf[ks.Key],
+			Type:    ks.Type,
+			Default: ks.Default,
+			Secret:  ks.Secret,
+			Doc:     ks.Doc,
+		}
+	}
+
+	return entries
+}
+
+var configHTML = template.Must(template.New("config").Parse(
+	"<!DOCTYPE html>\n<html>\n<head>\n<meta charset=\"utf-8\">\n<title>{{.Name}}</title>\n" +
+		"<style>body{font-family:sans-serif}table{border-collapse:collapse}" +
+		"th,td{border:1px solid #ccc;padding:4px 8px;text-align:left;vertical-align:top}" +
+		"td.doc{white-space:pre-wrap}</style>\n</head>\n<body>\n<h1>{{.Name}}</h1>\n<table>\n" +
+		"<tr><th>Key</th><th>Value</th><th>Source</th><th>Type</th><th>Default</th><th>Doc</th></tr>\n" +
+		"{{range .Entries}}<tr><td><code>{{.Key}}</code></td><td><code>{{.Value}}</code></td>" +
+		"<td>{{.Source}}</td><td>{{.Type}}</td><td><code>{{.Default}}</code></td>" +
+		"<td class=\"doc\">{{.Doc}}</td></tr>\n{{end}}</table>\n</body>\n</html>\n",
+))
+
+// ConfigHandler serves entries as JSON, or as an HTML table to requests
+// accepting HTML such as from a browser. ?format=json always serves JSON.
+func ConfigHandler(name string, entries func() []ConfigEntry) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		data := struct {
+			Name    string
+			Entries []ConfigEntry
+		}{Name: name, Entries: entries()}
+
+		w.Header().Set("Cache-Control", "no-store")
+		if r.URL.Query().Get("format") != "json" && strings.Contains(r.Header.Get("Accept"), "text/html") {
+			var buf bytes.Buffer
+			if err := configHTML.Execute(&buf, data); err != nil {
+				http.Error(w, err.Error(), http.StatusInternalServerError)
+				return
+			}
+
+			w.Header().Set("Content-Type", "text/html; charset=utf-8")
+			w.Write(buf.Bytes())
+			return
+		}
+
+		w.Header().Set("Content-Type", "application/json")
+		enc := json.NewEncoder(w)
+		enc.SetIndent("", "  ")
+		enc.Encode(data)
+	})
+}
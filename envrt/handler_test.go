@@ -0,0 +1,58 @@
+package envrt
+
+import (
+	"encoding/json"
+	"net/http/httptest"
+	"slices"
+	"strings"
+	"testing"
+)
+
+func TestConfigHandler(t *testing.T) {
+	schema := []KeySchema{
+		{Key: "HOST", Default: "localhost"},
+		{Key: "PORT"},
+		{Key: "TOKEN", Secret: true},
+	}
+	env := map[string]string{"HOST": "localhost", "PORT": "8080", "TOKEN": "hunter2"}
+	sources := FieldSources{
+		{Key: "HOST", Source: "default"},
+		{Key: "PORT", Source: "file .env"},
+		{Key: "TOKEN", Source: "env", Alias: "OLD_TOKEN"},
+	}
+
+	h := ConfigHandler("Config", func() []ConfigEntry {
+		return ConfigEntries(schema, env, sources)
+	})
+
+	rec := httptest.NewRecorder()
+	h.ServeHTTP(rec, httptest.NewRequest("GET", "/", nil))
+
+	var data struct {
+		Name    string
+		Entries []ConfigEntry
+	}
+	if err := json.Unmarshal(rec.Body.Bytes(), &data); err != nil {
+		t.Fatal(err)
+	}
+
+	var got []string
+	for _, e := range data.Entries {
+		got = append(got, e.Key+"="+e.Value+" "+e.Source)
+	}
+
+	want := []string{"HOST=localhost default", "PORT=8080 file .env", "TOKEN=[REDACTED] env (alias OLD_TOKEN)"}
+	if data.Name != "Config" || !slices.Equal(got, want) {
+		t.Errorf("got %v %q, want %q", data.Name, got, want)
+	}
+
+	rec = httptest.NewRecorder()
+	req := httptest.NewRequest("GET", "/", nil)
+	req.Header.Set("Accept", "text/html")
+	h.ServeHTTP(rec, req)
+
+	body := rec.Body.String()
+	if !strings.HasPrefix(rec.Header().Get("Content-Type"), "text/html") || strings.Contains(body, "hunter2") {
+		t.Errorf("expected a redacted html table, got %v:\n%v", rec.Header().Get("Content-Type"), body)
+	}
+}
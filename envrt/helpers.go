@@ -0,0 +1,67 @@
+package envrt
+
+import (
+	"log/slog"
+	"os"
+	"time"
+)
+
+// CountTrue returns how many of vs are true.
+func CountTrue(vs ...bool) int {
+	n := 0
+	for _, v := range vs {
+		if v {
+			n++
+		}
+	}
+
+	return n
+}
+
+// Redact returns what a secret value is printed as, unset values stay
+// empty so it is still clear they were not set.
+func Redact(set bool) string {
+	if set {
+		return "[REDACTED]"
+	}
+
+	return ""
+}
+
+// LogPtr logs the value of a nested config, slog would otherwise call
+// LogValue on a nil pointer.
+func LogPtr[T any](key string, v *T) slog.Attr {
+	if v == nil {
+		return slog.Any(key, nil)
+	}
+
+	return slog.Any(key, *v)
+}
+
+// ModTimes returns the modification time of each file, missing files
+// are left out so creating or removing one counts as a change.
+func ModTimes(paths []string) map[string]time.Time {
+	times := make(map[string]time.Time, len(paths))
+	for _, path := range paths {
+		if info, err := os.Stat(path); err == nil {
+			times[path] = info.ModTime()
+		}
+	}
+
+	return times
+}
+
+// ModTimesChanged checks if any file changed between two ModTimes.
+func ModTimesChanged(a, b map[string]time.Time) bool {
+	if len(a) != len(b) {
+		return true
+	}
+
+	for path, t := range a {
+		if !b[path].Equal(t) {
+			return true
+		}
+	}
+
+	return false
+}
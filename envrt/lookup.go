@@ -0,0 +1,286 @@
+package envrt
+
+import (
+	"fmt"
+	"os"
+	"slices"
+	"strings"
+)
+
+// Lookuper finds the value of an env var, os.LookupEnv is the default but
+// maps or other sources can be used instead.
+type Lookuper interface {
+	LookupEnv(key string) (string, bool)
+}
+
+// LookupFunc adapts a func with the same signature as os.LookupEnv.
+type LookupFunc func(key string) (string, bool)
+
+func (f LookupFunc) LookupEnv(key string) (string, bool) {
+	return f(key)
+}
+
+// MapLookup is a Lookuper backed by a map, useful for parallel tests.
+type MapLookup map[string]string
+
+func (m MapLookup) LookupEnv(key string) (string, bool) {
+	v, ok := m[key]
+	return v, ok
+}
+
+// Environ lists the entries of m as KEY=value sorted by key, so strict
+// checks report unknown keys in the same order on every run.
+func (m MapLookup) Environ() []string {
+	keys := make([]string, 0, len(m))
+	for k := range m {
+		keys = append(keys, k)
+	}
+
+	slices.Sort(keys)
+	environ := make([]string, len(keys))
+	for i, k := range keys {
+		environ[i] = k + "=" + m[k]
+	}
+
+	return environ
+}
+
+// ChainLookup checks each Lookuper in order and uses the first value found.
+type ChainLookup []Lookuper
+
+func (c ChainLookup) LookupEnv(key string) (string, bool) {
+	for _, l := range c {
+		if v, ok := l.LookupEnv(key); ok {
+			return v, true
+		}
+	}
+
+	return "", false
+}
+
+func (c ChainLookup) LookupSource(key string) (string, string, bool) {
+	for _, l := range c {
+		if v, source, ok := lookupSource(l, key); ok {
+			return v, source, true
+		}
+	}
+
+	return "", "", false
+}
+
+func (c ChainLookup) Environ() []string {
+	var environ []string
+	for _, l := range c {
+		environ = append(environ, EnvironOf(l)...)
+	}
+
+	return environ
+}
+
+type osLookup struct{}
+
+func (osLookup) LookupEnv(key string) (string, bool) {
+	return os.LookupEnv(key)
+}
+
+func (osLookup) LookupSource(key string) (string, string, bool) {
+	v, ok := os.LookupEnv(key)
+	return v, "env", ok
+}
+
+func (osLookup) Environ() []string {
+	return os.Environ()
+}
+
+// OSLookup reads from the environment of the current process.
+var OSLookup Lookuper = osLookup{}
+
+// EnvironOf lists every key=value pair of l if it supports listing them.
+func EnvironOf(l Lookuper) []string {
+	if e, ok := l.(interface{ Environ() []string }); ok {
+		return e.Environ()
+	}
+
+	return nil
+}
+
+// SourceLookuper is a Lookuper able to name where it found a key, such as
+// "env" or "file .env".
+type SourceLookuper interface {
+	Lookuper
+	LookupSource(key string) (value, source string, ok bool)
+}
+
+// NamedLookup is a Lookuper reporting Name as the source of its values.
+type NamedLookup struct {
+	Name string
+	Lookuper
+}
+
+func (n NamedLookup) LookupSource(key string) (string, string, bool) {
+	v, ok := n.LookupEnv(key)
+	return v, n.Name, ok
+}
+
+func (n NamedLookup) Environ() []string {
+	return EnvironOf(n.Lookuper)
+}
+
+// lookupSource finds key in l along with its source, lookups unable to
+// name their source are reported as "lookup".
+func lookupSource(l Lookuper, key string) (string, string, bool) {
+	if sl, ok := l.(SourceLookuper); ok {
+		return sl.LookupSource(key)
+	}
+
+	v, ok := l.LookupEnv(key)
+	return v, "lookup", ok
+}
+
+// DeprecationLookup is a Lookuper calling OnDeprecated when a deprecated
+// alias of a key is set. It is found inside chains and named lookups, so
+// only the source with aliases has to be wrapped. Deprecations are not
+// reported anywhere without one.
+type DeprecationLookup struct {
+	Lookuper
+	OnDeprecated func(alias, key string)
+}
+
+func (d DeprecationLookup) LookupSource(key string) (string, string, bool) {
+	return lookupSource(d.Lookuper, key)
+}
+
+func (d DeprecationLookup) Environ() []string {
+	return EnvironOf(d.Lookuper)
+}
+
+// reportDeprecated reports that alias of key is set to the first
+// DeprecationLookup found in l, if any.
+func reportDeprecated(l Lookuper, alias, key string) {
+	if onDeprecated := deprecationHook(l); onDeprecated != nil {
+		onDeprecated(alias, key)
+	}
+}
+
+// deprecationHook finds the OnDeprecated hook of l or the lookups it
+// wraps, the first lookup of a chain with a hook wins.
+func deprecationHook(l Lookuper) func(alias, key string) {
+	switch l := l.(type) {
+	case DeprecationLookup:
+		if l.OnDeprecated != nil {
+			return l.OnDeprecated
+		}
+
+		return deprecationHook(l.Lookuper)
+	case NamedLookup:
+		return deprecationHook(l.Lookuper)
+	case ChainLookup:
+		for _, inner := range l {
+			if onDeprecated := deprecationHook(inner); onDeprecated != nil {
+				return onDeprecated
+			}
+		}
+	}
+
+	return nil
+}
+
+// LookupEnv finds the value of key or, if it is missing, the first of
+// its aliases that is set. Aliases setting a different value are errors.
+func LookupEnv(l Lookuper, key string, aliases ...string) (string, bool, error) {
+	fs, ok, err := lookupKey(l, key, aliases...)
+	return fs.Value, ok, err
+}
+
+// lookupKey is LookupEnv returning where the value was found.
+func lookupKey(l Lookuper, key string, aliases ...string) (FieldSource, bool, error) {
+	fs := FieldSource{Key: key}
+	v, source, ok := lookupSource(l, key)
+	usedKey := key
+
+	for _, alias := range aliases {
+		av, asource, aok := lookupSource(l, alias)
+		if !aok {
+			continue
+		}
+
+		reportDeprecated(l, alias, key)
+		if !ok {
+			v, source, ok, usedKey = av, asource, true, alias
+			fs.Alias = alias
+			continue
+		}
+
+		if av != v {
+			return fs, false, &FieldError{
+				Key: key,
+				Err: fmt.Errorf("%w: %v and %v", ErrConflictingKeys, usedKey, alias),
+			}
+		}
+	}
+
+	fs.Value, fs.Source = v, source
+	return fs, ok, nil
+}
+
+// ExpandRefs replaces each ${KEY} in v with the value of KEY under prefix,
+// looked up the same way as any other key and expanded in turn. Seen holds
+// the keys being expanded to catch cycles and $${ is written as a literal ${.
+func ExpandRefs(l, d Lookuper, prefix, v string, seen []string) (string, error) {
+	if !strings.Contains(v, "${") {
+		return v, nil
+	}
+
+	var sb strings.Builder
+	for {
+		i := strings.Index(v, "${")
+		if i < 0 {
+			sb.WriteString(v)
+			return sb.String(), nil
+		}
+
+		if i > 0 && v[i-1] == '$' {
+			sb.WriteString(v[:i-1] + "${")
+			v = v[i+2:]
+			continue
+		}
+
+		end := strings.IndexByte(v[i+2:], '}')
+		if end < 0 {
+			return "", fmt.Errorf("%w: %v", ErrUnclosedRef, v[i:])
+		}
+
+		sb.WriteString(v[:i])
+		ref := v[i+2 : i+2+end]
+		if prefix != "" {
+			ref = prefix + "_" + ref
+		}
+		v = v[i+3+end:]
+
+		for _, key := range seen {
+			if key == ref {
+				return "", fmt.Errorf("%w: %v", ErrRefCycle, strings.Join(append(seen, ref), " -> "))
+			}
+		}
+
+		rv, ok, err := LookupEnv(l, ref)
+		if err != nil {
+			return "", err
+		}
+
+		if !ok {
+			rv, ok = d.LookupEnv(ref)
+		}
+
+		if !ok {
+			return "", fmt.Errorf("%w: %v", ErrUnresolvedRef, ref)
+		}
+
+		rv, err = ExpandRefs(l, d, prefix, rv, append(seen, ref))
+		if err != nil {
+			return "", err
+		}
+
+		sb.WriteString(rv)
+	}
+}
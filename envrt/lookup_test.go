@@ -0,0 +1,178 @@
+package envrt
+
+import (
+	"errors"
+	"slices"
+	"testing"
+)
+
+func TestExpandRefs(t *testing.T) {
+	env := MapLookup{
+		"HOST":  "localhost",
+		"PORT":  "8080",
+		"ADDR":  "${HOST}:${PORT}",
+		"URL":   "http://${ADDR}/",
+		"SELF":  "${SELF}",
+		"PING":  "${PONG}",
+		"PONG":  "${PING}",
+		"EMPTY": "",
+	}
+	defaults := MapLookup{
+		"PORT":   "80",
+		"SCHEME": "https",
+	}
+
+	for _, tc := range []struct {
+		name    string
+		value   string
+		want    string
+		wantErr error
+	}{
+		{name: "no refs", value: "plain", want: "plain"},
+		{name: "single", value: "${HOST}", want: "localhost"},
+		{name: "surrounded", value: "a${HOST}b", want: "alocalhostb"},
+		{name: "nested", value: "${URL}", want: "http://localhost:8080/"},
+		{name: "default", value: "${SCHEME}://${HOST}", want: "https://localhost"},
+		{name: "env over default", value: "${PORT}", want: "8080"},
+		{name: "empty", value: "[${EMPTY}]", want: "[]"},
+		{name: "escaped", value: "$${HOST}", want: "${HOST}"},
+		{name: "escaped then ref", value: "$${HOST}${HOST}", want: "${HOST}localhost"},
+		{name: "lone dollar", value: "$HOST $", want: "$HOST $"},
+		{name: "unknown", value: "${MISSING}", wantErr: ErrUnresolvedRef},
+		{name: "unclosed", value: "${HOST", wantErr: ErrUnclosedRef},
+		{name: "self cycle", value: "${SELF}", wantErr: ErrRefCycle},
+		{name: "cycle", value: "${PING}", wantErr: ErrRefCycle},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			got, err := ExpandRefs(env, defaults, "", tc.value, []string{"VALUE"})
+			if tc.wantErr != nil {
+				if !errors.Is(err, tc.wantErr) {
+					t.Fatalf("expected %v, got %q %v", tc.wantErr, got, err)
+				}
+
+				return
+			}
+
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			if got != tc.want {
+				t.Fatalf("got %q, want %q", got, tc.want)
+			}
+		})
+	}
+}
+
+func TestExpandRefsCycleOfKey(t *testing.T) {
+	_, err := ExpandRefs(MapLookup{"B": "${A}"}, MapLookup{}, "", "${B}", []string{"A"})
+	if !errors.Is(err, ErrRefCycle) {
+		t.Fatalf("expected ErrRefCycle, got %v", err)
+	}
+
+	if want := "env var references form a cycle: A -> B -> A"; err.Error() != want {
+		t.Fatalf("got %q, want %q", err, want)
+	}
+}
+
+func TestExpandRefsPrefix(t *testing.T) {
+	env := MapLookup{"HOST": "unprefixed", "APP_HOST": "localhost", "APP_ADDR": "${HOST}:80"}
+	got, err := ExpandRefs(env, MapLookup{"APP_PORT": "80"}, "APP", "${ADDR} ${PORT}", []string{"APP_URL"})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if want := "localhost:80 80"; got != want {
+		t.Fatalf("got %q, want %q", got, want)
+	}
+
+	_, err = ExpandRefs(MapLookup{"APP_A": "${URL}"}, MapLookup{}, "APP", "${A}", []string{"APP_URL"})
+	if !errors.Is(err, ErrRefCycle) {
+		t.Fatalf("expected ErrRefCycle, got %v", err)
+	}
+}
+
+func TestLookupEnvAliases(t *testing.T) {
+	for _, tc := range []struct {
+		name           string
+		env            MapLookup
+		want           string
+		wantOk         bool
+		wantDeprecated bool
+		wantErr        error
+	}{
+		{name: "missing", env: MapLookup{}},
+		{name: "key", env: MapLookup{"NEW": "a"}, want: "a", wantOk: true},
+		{name: "alias", env: MapLookup{"OLD": "a"}, want: "a", wantOk: true, wantDeprecated: true},
+		{name: "same value", env: MapLookup{"NEW": "a", "OLD": "a"}, want: "a", wantOk: true, wantDeprecated: true},
+		{name: "conflict", env: MapLookup{"NEW": "a", "OLD": "b"}, wantDeprecated: true, wantErr: ErrConflictingKeys},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			deprecated := false
+			l := DeprecationLookup{
+				Lookuper: tc.env,
+				OnDeprecated: func(alias, key string) {
+					deprecated = alias == "OLD" && key == "NEW"
+				},
+			}
+
+			got, ok, err := LookupEnv(l, "NEW", "OLD")
+			if !errors.Is(err, tc.wantErr) {
+				t.Fatalf("expected %v, got %v", tc.wantErr, err)
+			}
+
+			if got != tc.want || ok != tc.wantOk {
+				t.Fatalf("got %q %v, want %q %v", got, ok, tc.want, tc.wantOk)
+			}
+
+			if deprecated != tc.wantDeprecated {
+				t.Fatalf("expected deprecated to be %v", tc.wantDeprecated)
+			}
+		})
+	}
+}
+
+func TestDeprecationLookupWrapped(t *testing.T) {
+	var reported []string
+	deprecations := DeprecationLookup{
+		Lookuper: MapLookup{"OLD": "a"},
+		OnDeprecated: func(alias, key string) {
+			reported = append(reported, alias+"->"+key)
+		},
+	}
+
+	for _, tc := range []struct {
+		name string
+		l    Lookuper
+		want []string
+	}{
+		{name: "none", l: MapLookup{"OLD": "a"}},
+		{name: "direct", l: deprecations, want: []string{"OLD->NEW"}},
+		{name: "chain", l: ChainLookup{MapLookup{}, deprecations}, want: []string{"OLD->NEW"}},
+		{name: "named", l: ChainLookup{NamedLookup{Name: "file", Lookuper: deprecations}}, want: []string{"OLD->NEW"}},
+		{name: "without a hook", l: ChainLookup{DeprecationLookup{Lookuper: MapLookup{"OLD": "a"}}}},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			reported = nil
+			got, ok, err := LookupEnv(tc.l, "NEW", "OLD")
+			if err != nil || !ok || got != "a" {
+				t.Fatalf("got %q %v %v", got, ok, err)
+			}
+
+			if !slices.Equal(reported, tc.want) {
+				t.Fatalf("got %q, want %q", reported, tc.want)
+			}
+		})
+	}
+}
+
+func TestMapLookupEnviron(t *testing.T) {
+	m := MapLookup{"PORT": "80", "HOST": "localhost", "ADDR": "${HOST}:${PORT}", "HOST_NAME": "web", "PORT2": "81"}
+
+	want := []string{"ADDR=${HOST}:${PORT}", "HOST=localhost", "HOST_NAME=web", "PORT=80", "PORT2=81"}
+	for i := 0; i < 10; i++ {
+		if got := m.Environ(); !slices.Equal(got, want) {
+			t.Fatalf("got %q, want %q", got, want)
+		}
+	}
+}
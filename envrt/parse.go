@@ -0,0 +1,233 @@
+package envrt
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Loader holds the lookups a config is loaded from and records the
+// source of each key as it is loaded.
+type Loader struct {
+	Lookup   Lookuper
+	Defaults Lookuper
+	// Prefix is the prefix of the root config, references are keys
+	// relative to it
+	Prefix  string
+	Sources FieldSources
+}
+
+// ParseRequired finds key, or the first of its aliases that is set, and
+// converts it with conv. Missing keys are an ErrKeyNotFound.
+func ParseRequired[T any](ld *Loader, key string, conv func(string) (T, error), aliases ...string) (T, error) {
+	var zero T
+	fs, ok, err := lookupKey(ld.Lookup, key, aliases...)
+	if err != nil {
+		return zero, err
+	}
+
+	if !ok {
+		return zero, &FieldError{Key: key, Err: ErrKeyNotFound}
+	}
+
+	return parseValue(ld, fs, conv)
+}
+
+// ParseOptional is ParseRequired falling back to the default of key, keys
+// missing both are the zero value.
+func ParseOptional[T any](ld *Loader, key string, conv func(string) (T, error), aliases ...string) (T, error) {
+	var zero T
+	fs, ok, err := lookupKey(ld.Lookup, key, aliases...)
+	if err != nil {
+		return zero, err
+	}
+
+	if !ok {
+		// defaults are the lowest layer so are only checked once every
+		// other source and alias is missing
+		fs.Value, ok = ld.Defaults.LookupEnv(key)
+		fs.Source = "default"
+		if !ok {
+			ld.Sources = append(ld.Sources, FieldSource{Key: key, Source: "unset"})
+			return zero, nil
+		}
+	}
+
+	return parseValue(ld, fs, conv)
+}
+
+// ParseSliceRequired is ParseRequired for comma separated values.
+func ParseSliceRequired[T any](ld *Loader, key string, conv func(string) (T, error), aliases ...string) ([]T, error) {
+	return ParseRequired(ld, key, sliceConv(key, conv), aliases...)
+}
+
+// ParseSliceOptional is ParseOptional for comma separated values.
+func ParseSliceOptional[T any](ld *Loader, key string, conv func(string) (T, error), aliases ...string) ([]T, error) {
+	return ParseOptional(ld, key, sliceConv(key, conv), aliases...)
+}
+
+// parseValue expands and converts the value found for a key, recording
+// where it was found.
+func parseValue[T any](ld *Loader, fs FieldSource, conv func(string) (T, error)) (T, error) {
+	var zero T
+	v, err := ExpandRefs(ld.Lookup, ld.Defaults, ld.Prefix, fs.Value, []string{fs.Key})
+	if err != nil {
+		// some expand errors quote part of the value
+		return zero, &FieldError{Key: fs.Key, Value: fs.Value, Err: err}
+	}
+
+	fs.Value = v
+	ld.Sources = append(ld.Sources, fs)
+
+	pv, err := conv(v)
+	if err != nil {
+		// slice errors already name the whole value
+		if _, ok := err.(*FieldError); ok {
+			return pv, err
+		}
+
+		return pv, &FieldError{Key: fs.Key, Value: v, Err: err}
+	}
+
+	return pv, nil
+}
+
+func sliceConv[T any](key string, conv func(string) (T, error)) func(string) ([]T, error) {
+	return func(v string) ([]T, error) {
+		if v == "" {
+			return nil, nil
+		}
+
+		var vs []T
+		for _, part := range SplitSlice(v) {
+			pv, err := conv(part)
+			if err != nil {
+				return nil, &FieldError{Key: key, Value: v, Err: err}
+			}
+
+			vs = append(vs, pv)
+		}
+
+		return vs, nil
+	}
+}
+
+func ConvString(v string) (string, error) {
+	return v, nil
+}
+
+func ConvInt(v string) (int, error) {
+	v64, err := strconv.ParseInt(v, 10, 64)
+	if err != nil {
+		return 0, err
+	}
+
+	return int(v64), nil
+}
+
+func ConvBool(v string) (bool, error) {
+	switch strings.ToLower(v) {
+	case "y", "yes", "true", "t", "1", "on":
+		return true, nil
+	case "n", "no", "false", "f", "0", "off":
+		return false, nil
+	default:
+		return false, fmt.Errorf("%w: %v", ErrInvalidBool, v)
+	}
+}
+
+func ConvTimeDuration(v string) (time.Duration, error) {
+	vd, err := time.ParseDuration(v)
+	if err != nil {
+		return 0, err
+	}
+
+	return vd, nil
+}
+
+// FormatString escapes references so they are not expanded when loaded.
+func FormatString(v string) string {
+	return strings.ReplaceAll(v, "${", "$${")
+}
+
+func FormatInt(v int) string {
+	return strconv.Itoa(v)
+}
+
+func FormatBool(v bool) string {
+	return strconv.FormatBool(v)
+}
+
+func FormatTimeDuration(v time.Duration) string {
+	return v.String()
+}
+
+// FormatSlice joins formatted values with commas the way SplitSlice splits
+// them, escaping commas, backslashes and surrounding spaces. A slice of a
+// single empty value is formatted the same as an empty slice.
+func FormatSlice[T any](vs []T, format func(T) string) string {
+	parts := make([]string, len(vs))
+	for i, v := range vs {
+		part := format(v)
+		part = strings.ReplaceAll(part, `\`, `\\`)
+		part = strings.ReplaceAll(part, ",", `\,`)
+
+		// only the outer spaces are escaped, those inside are kept as is
+		trimmed := strings.TrimLeft(part, " \t")
+		part = escapeSpaces(part[:len(part)-len(trimmed)]) + trimmed
+		trimmed = strings.TrimRight(part, " \t")
+		part = trimmed + escapeSpaces(part[len(trimmed):])
+
+		parts[i] = part
+	}
+
+	return strings.Join(parts, ",")
+}
+
+func escapeSpaces(spaces string) string {
+	var sb strings.Builder
+	for _, r := range spaces {
+		sb.WriteRune('\\')
+		sb.WriteRune(r)
+	}
+
+	return sb.String()
+}
+
+// SplitSlice splits a comma separated value, trimming the spaces around
+// each value. A backslash escapes a comma, a space or another backslash,
+// any other backslash is kept as is.
+func SplitSlice(v string) []string {
+	var (
+		parts []string
+		sb    strings.Builder
+		// kept is the length of sb up to its last escaped rune, which is
+		// never trimmed
+		kept int
+	)
+
+	split := func() {
+		part := strings.TrimRight(sb.String()[kept:], " \t")
+		parts = append(parts, sb.String()[:kept]+part)
+		sb.Reset()
+		kept = 0
+	}
+
+	for i := 0; i < len(v); i++ {
+		switch c := v[i]; {
+		case c == '\\' && i+1 < len(v) && strings.ContainsRune("\\, \t", rune(v[i+1])):
+			i++
+			sb.WriteByte(v[i])
+			kept = sb.Len()
+		case c == ',':
+			split()
+		case (c == ' ' || c == '\t') && sb.Len() == 0:
+		default:
+			sb.WriteByte(c)
+		}
+	}
+	split()
+
+	return parts
+}
@@ -0,0 +1,143 @@
+package envrt
+
+import (
+	"errors"
+	"slices"
+	"testing"
+	"time"
+)
+
+func TestConv(t *testing.T) {
+	for _, tc := range []struct {
+		name    string
+		conv    func(string) (any, error)
+		value   string
+		want    any
+		wantErr bool
+	}{
+		{name: "string", conv: anyConv(ConvString), value: " a b ", want: " a b "},
+		{name: "int", conv: anyConv(ConvInt), value: "-42", want: -42},
+		{name: "int invalid", conv: anyConv(ConvInt), value: "4.2", wantErr: true},
+		{name: "int empty", conv: anyConv(ConvInt), value: "", wantErr: true},
+		{name: "bool yes", conv: anyConv(ConvBool), value: "Yes", want: true},
+		{name: "bool on", conv: anyConv(ConvBool), value: "on", want: true},
+		{name: "bool off", conv: anyConv(ConvBool), value: "OFF", want: false},
+		{name: "bool zero", conv: anyConv(ConvBool), value: "0", want: false},
+		{name: "bool invalid", conv: anyConv(ConvBool), value: "maybe", wantErr: true},
+		{name: "duration", conv: anyConv(ConvTimeDuration), value: "1m30s", want: 90 * time.Second},
+		{name: "duration invalid", conv: anyConv(ConvTimeDuration), value: "90", wantErr: true},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			got, err := tc.conv(tc.value)
+			if tc.wantErr {
+				if err == nil {
+					t.Fatalf("expected an error, got %v", got)
+				}
+
+				return
+			}
+
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			if got != tc.want {
+				t.Fatalf("got %v, want %v", got, tc.want)
+			}
+		})
+	}
+}
+
+func TestConvBoolError(t *testing.T) {
+	if _, err := ConvBool("maybe"); !errors.Is(err, ErrInvalidBool) {
+		t.Fatalf("expected ErrInvalidBool, got %v", err)
+	}
+}
+
+func TestFormatRoundTrip(t *testing.T) {
+	for _, v := range []string{"", "plain", "${HOST}", "$${HOST}", "a,b"} {
+		got, err := ExpandRefs(MapLookup{}, MapLookup{}, "", FormatString(v), nil)
+		if err != nil {
+			t.Fatalf("%q: %v", v, err)
+		}
+
+		if got != v {
+			t.Fatalf("got %q, want %q", got, v)
+		}
+	}
+
+	for _, v := range []time.Duration{0, time.Millisecond, 90 * time.Minute} {
+		if got, err := ConvTimeDuration(FormatTimeDuration(v)); err != nil || got != v {
+			t.Fatalf("got %v %v, want %v", got, err, v)
+		}
+	}
+
+	for _, v := range []int{0, -1, 1 << 40} {
+		if got, err := ConvInt(FormatInt(v)); err != nil || got != v {
+			t.Fatalf("got %v %v, want %v", got, err, v)
+		}
+	}
+}
+
+func anyConv[T any](conv func(string) (T, error)) func(string) (any, error) {
+	return func(v string) (any, error) {
+		return conv(v)
+	}
+}
+
+func TestSplitSlice(t *testing.T) {
+	for _, tc := range []struct {
+		name  string
+		value string
+		want  []string
+	}{
+		{name: "single", value: "a", want: []string{"a"}},
+		{name: "trimmed", value: " a , b ,c ", want: []string{"a", "b", "c"}},
+		{name: "inner spaces", value: "a b, c  d", want: []string{"a b", "c  d"}},
+		{name: "empty values", value: "a,,b,", want: []string{"a", "", "b", ""}},
+		{name: "escaped comma", value: `a\,b,c`, want: []string{"a,b", "c"}},
+		{name: "escaped backslash", value: `a\\,b`, want: []string{`a\`, "b"}},
+		{name: "escaped spaces", value: `\ a\ ,  \ b`, want: []string{" a ", " b"}},
+		{name: "escaped tab", value: "\\\ta", want: []string{"\ta"}},
+		{name: "other backslash", value: `C:\dir\x,y`, want: []string{`C:\dir\x`, "y"}},
+		{name: "trailing backslash", value: `a\`, want: []string{`a\`}},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			if got := SplitSlice(tc.value); !slices.Equal(got, tc.want) {
+				t.Fatalf("got %q, want %q", got, tc.want)
+			}
+		})
+	}
+}
+
+func TestFormatSliceRoundTrip(t *testing.T) {
+	for _, vs := range [][]string{
+		nil,
+		{"a"},
+		{"a", "b"},
+		{"a,b", "c"},
+		{" a", "b ", " c "},
+		{"\ta\t"},
+		{`a\`, `\,`, `\\`},
+		{`C:\dir`},
+		{"", ""},
+		{"a", ""},
+		{"inner  spaces"},
+	} {
+		formatted := FormatSlice(vs, FormatString)
+		got, err := sliceConv("KEY", ConvString)(formatted)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if !slices.Equal(got, vs) {
+			t.Fatalf("%q formatted as %q loads as %q", vs, formatted, got)
+		}
+	}
+
+	durations := []time.Duration{time.Second, 90 * time.Minute}
+	got, err := sliceConv("KEY", ConvTimeDuration)(FormatSlice(durations, FormatTimeDuration))
+	if err != nil || !slices.Equal(got, durations) {
+		t.Fatalf("got %v %v, want %v", got, err, durations)
+	}
+}
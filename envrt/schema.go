@@ -0,0 +1,26 @@
+package envrt
+
+// KeySchema describes a single key loaded by a config, as listed in the
+// generated schema of each root config.
+type KeySchema struct {
+	Key string
+	// GoPath is the path of the field from the root config, such as
+	// DataStore.SQLite.File
+	GoPath     string
+	Type       string
+	Default    string
+	HasDefault bool
+	Required   bool
+	Secret     bool
+	// Allowed lists every value accepted, empty if any value is
+	Allowed []string
+	// Constraints are the validation tags of the field, such as min=1
+	Constraints []string
+	// Aliases are deprecated keys still loaded in place of Key
+	Aliases []string
+	// LoadIf is set when the key is only loaded for one build type, such
+	// as DATA_STORE_TYPE=SQLITE
+	LoadIf     string
+	Deprecated bool
+	Doc        string
+}
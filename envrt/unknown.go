@@ -0,0 +1,113 @@
+package envrt
+
+import (
+	"fmt"
+	"strings"
+)
+
+// UnknownKeyError is an env var under a prefix owned by a config that
+// no field uses, often a typo of the key that was wanted.
+type UnknownKeyError struct {
+	Key        string
+	Suggestion string
+}
+
+func (e *UnknownKeyError) Error() string {
+	if e.Suggestion == "" {
+		return fmt.Sprintf("%v: %v", ErrUnknownKey, e.Key)
+	}
+
+	return fmt.Sprintf("%v: %v, did you mean %v?", ErrUnknownKey, e.Key, e.Suggestion)
+}
+
+func (e *UnknownKeyError) Unwrap() error {
+	return ErrUnknownKey
+}
+
+// KnownKeys is every key used by a config along with the prefixes it owns.
+type KnownKeys struct {
+	keys     []string
+	prefixes []string
+}
+
+func (k *KnownKeys) Add(key string) {
+	k.keys = append(k.keys, key)
+}
+
+func (k *KnownKeys) Own(prefix string) {
+	k.prefixes = append(k.prefixes, prefix+"_")
+}
+
+// Check returns an error for every key in environ that is under one of
+// our prefixes but not one of our keys.
+func (k *KnownKeys) Check(environ []string) error {
+	var errs ConfigErrors
+
+	for _, kv := range environ {
+		key, _, _ := strings.Cut(kv, "=")
+		if !k.owns(key) || k.has(key) {
+			continue
+		}
+
+		errs.Add(&UnknownKeyError{Key: key, Suggestion: k.closest(key)})
+	}
+
+	return errs.Err()
+}
+
+func (k *KnownKeys) owns(key string) bool {
+	for _, prefix := range k.prefixes {
+		if strings.HasPrefix(key, prefix) {
+			return true
+		}
+	}
+
+	return false
+}
+
+func (k *KnownKeys) has(key string) bool {
+	for _, known := range k.keys {
+		if known == key {
+			return true
+		}
+	}
+
+	return false
+}
+
+// closest returns the known key with the smallest edit distance to key,
+// or an empty string if none are close enough to be a typo.
+func (k *KnownKeys) closest(key string) string {
+	best, bestDist := "", 3
+	for _, known := range k.keys {
+		if dist := editDistance(key, known); dist < bestDist {
+			best, bestDist = known, dist
+		}
+	}
+
+	return best
+}
+
+func editDistance(a, b string) int {
+	prev := make([]int, len(b)+1)
+	curr := make([]int, len(b)+1)
+	for j := range prev {
+		prev[j] = j
+	}
+
+	for i := 1; i <= len(a); i++ {
+		curr[0] = i
+		for j := 1; j <= len(b); j++ {
+			cost := 1
+			if a[i-1] == b[j-1] {
+				cost = 0
+			}
+
+			curr[j] = min(prev[j]+1, curr[j-1]+1, prev[j-1]+cost)
+		}
+
+		prev, curr = curr, prev
+	}
+
+	return prev[len(b)]
+}
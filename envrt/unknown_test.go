@@ -0,0 +1,72 @@
+package envrt
+
+import (
+	"errors"
+	"testing"
+)
+
+func TestKnownKeysCheck(t *testing.T) {
+	var k KnownKeys
+	k.Own("APP")
+	k.Add("APP_HOST")
+	k.Add("APP_PORT")
+
+	err := k.Check([]string{
+		"APP_HOST=localhost",
+		"APP_PROT=80",
+		"APP_SOMETHING_ELSE=1",
+		"OTHER_HOST=example.com",
+		"APPLE=1",
+	})
+
+	var errs ConfigErrors
+	if !errors.As(err, &errs) {
+		t.Fatalf("expected ConfigErrors, got %v", err)
+	}
+
+	want := []UnknownKeyError{
+		{Key: "APP_PROT", Suggestion: "APP_PORT"},
+		{Key: "APP_SOMETHING_ELSE"},
+	}
+	if len(errs) != len(want) {
+		t.Fatalf("got %v, want %v errors", errs, len(want))
+	}
+
+	for i, w := range want {
+		var ue *UnknownKeyError
+		if !errors.As(errs[i], &ue) || *ue != w {
+			t.Fatalf("error %v: got %v, want %+v", i, errs[i], w)
+		}
+
+		if !errors.Is(errs[i], ErrUnknownKey) {
+			t.Fatalf("error %v does not wrap ErrUnknownKey", i)
+		}
+	}
+}
+
+func TestKnownKeysCheckClean(t *testing.T) {
+	var k KnownKeys
+	k.Own("APP")
+	k.Add("APP_HOST")
+
+	if err := k.Check([]string{"APP_HOST=localhost", "HOME=/root"}); err != nil {
+		t.Fatalf("expected no error, got %v", err)
+	}
+}
+
+func TestEditDistance(t *testing.T) {
+	for _, tc := range []struct {
+		a, b string
+		want int
+	}{
+		{"", "", 0},
+		{"PORT", "PORT", 0},
+		{"PORT", "PROT", 2},
+		{"HOST", "HOSTS", 1},
+		{"", "ABC", 3},
+	} {
+		if got := editDistance(tc.a, tc.b); got != tc.want {
+			t.Errorf("editDistance(%q, %q) = %v, want %v", tc.a, tc.b, got, tc.want)
+		}
+	}
+}
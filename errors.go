@@ -0,0 +1,57 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"strings"
+)
+
+// goType returns the go type of the field as written in the struct.
+func (f *Field) goType() string {
+	switch {
+	case f.slice:
+		return "[]" + f.typeName
+	case f.pointer:
+		return "*" + f.typeName
+	default:
+		return f.typeName
+	}
+}
+
+// writeDescribe writes adding our go field details to any error in err.
+func (f *Field) writeDescribe(w io.Writer) {
+	f.templates.Use("DescribeField")
+	writeF(w, "\nerr = describeField(err, %v)", f.describeArgs())
+}
+
+// describeArgs returns the go field details of our errors as the args of
+// describeField following the error.
+func (f *Field) describeArgs() string {
+	return fmt.Sprintf(
+		"%q, %q, %q, %v",
+		f.structName+"."+f.goPath,
+		f.goType(),
+		strings.TrimSpace(f.docs),
+		f.secret,
+	)
+}
+
+// fieldErrorExpr returns a go expression creating a field error for our
+// current value failing with errExpr.
+func (f *Field) fieldErrorExpr(envKey, errExpr string) string {
+	f.templates.Use("FieldError")
+	f.importCache.Add("fmt", "fmt")
+	return fmt.Sprintf(
+		"&FieldError{Key: %v, Value: fmt.Sprint(c.%v), Err: %v}",
+		envKey,
+		f.goPath,
+		errExpr,
+	)
+}
+
+// describedErrorExpr is fieldErrorExpr with our go field details, for
+// failures found once every field is loaded.
+func (f *Field) describedErrorExpr(envKey, errExpr string) string {
+	f.templates.Use("DescribeField")
+	return fmt.Sprintf("describeField(%v, %v)", f.fieldErrorExpr(envKey, errExpr), f.describeArgs())
+}
@@ -2,16 +2,27 @@ package main
 
 import "fmt"
 
-//go:generate go run ../../. --config=Config --file config_gen.go --env .env.example --verbose
+//go:generate go run ../../. --package main --config=Config --file config_gen.go --env .env.example --aggregate --flags pflag --with watcher,handler,compare --verbose
 
 type Config struct {
 	// Host will configure the http server for what hostname to listen on
-	Host string `default:"localhost"`
+	Host string `default:"localhost" reload:"false"`
 	// Port will configure the HTTP port to listen on
-	Port int `default:"3000"`
+	Port int `default:"3000" min:"1" max:"65535" reload:"false"`
+	// AdminToken protects the admin endpoints, they are disabled if empty
+	AdminToken string `default:""`
 
 	// DataStore handles storing our data for key values
 	DataStore *DataStoreConfig
+
+	// LoggingConfig fields are promoted, so the level loads from LOG_LEVEL
+	LoggingConfig
+}
+
+// LoggingConfig is shared between services to configure logging.
+type LoggingConfig struct {
+	// LogLevel sets the minimum level of logs to output
+	LogLevel string `default:"info" aliases:"LOGGING_LEVEL"`
 }
 
 func (c *Config) NewServer() (*Server, error) {
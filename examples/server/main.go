@@ -1,30 +1,133 @@
 package main
 
 import (
+	"context"
+	"crypto/subtle"
+	"fmt"
 	"log"
+	"log/slog"
 	"net/http"
+	"net/http/pprof"
+	"os"
+
+	"github.com/spf13/pflag"
 )
 
 type Server struct {
 	Address   string
 	DataStore DataStore
+	// Mux serves every route, importing pprof registers its handlers on
+	// http.DefaultServeMux so that is never served
+	Mux *http.ServeMux
 }
 
 func (s *Server) Serve() error {
-	return http.ListenAndServe(s.Address, nil)
+	return http.ListenAndServe(s.Address, s.Mux)
+}
+
+// requireToken only lets requests with an "Authorization: Bearer <token>"
+// header through to next.
+func requireToken(token string, next http.Handler) http.Handler {
+	want := []byte("Bearer " + token)
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if subtle.ConstantTimeCompare([]byte(r.Header.Get("Authorization")), want) != 1 {
+			http.Error(w, http.StatusText(http.StatusUnauthorized), http.StatusUnauthorized)
+			return
+		}
+
+		next.ServeHTTP(w, r)
+	})
 }
 
 func main() {
-	config, err := NewConfig()
+	fs := pflag.NewFlagSet("server", pflag.ExitOnError)
+	flags := BindConfigFlags(fs)
+	explain := fs.Bool("explain-config", false, "print where each config value comes from and exit")
+	fs.Parse(os.Args[1:])
+
+	paths := DotenvCascade(os.Getenv("APP_PROFILE"))
+	lookup := func() (Lookuper, error) {
+		files, err := LoadDotenv(paths...)
+		if err != nil {
+			return nil, err
+		}
+
+		// flags win over the environment which wins over dotenv files,
+		// renamed keys still set in any of them are logged
+		return DeprecationLookup{
+			Lookuper: ChainLookup{flags, OSLookup, files},
+			OnDeprecated: func(alias, key string) {
+				log.Printf("env var %v is deprecated, use %v instead", alias, key)
+			},
+		}, nil
+	}
+
+	load := func() (*Config, FieldSources, error) {
+		l, err := lookup()
+		if err != nil {
+			return nil, nil, err
+		}
+
+		return LoadConfig(l)
+	}
+
+	if *explain {
+		l, err := lookup()
+		if err != nil {
+			log.Fatal(err)
+		}
+
+		// failed keys are explained too, so print the sources either way
+		sources, err := ExplainConfig(l)
+		fmt.Print(sources)
+		if err != nil {
+			log.Fatal(err)
+		}
+
+		return
+	}
+
+	watcher, err := NewConfigWatcher(load)
 	if err != nil {
 		log.Fatal(err)
 	}
 
+	// the log level can change without a restart, host and port can not,
+	// changes are logged with secrets such as the admin token redacted
+	watcher.Subscribe(func(old, new *Config) {
+		for _, change := range old.Diff(new) {
+			log.Printf("config %v changed from %q to %q", change.Key, change.Old, change.New)
+		}
+	})
+
+	go watcher.Watch(context.Background(), func(err error) {
+		log.Println("reloading config:", err)
+	}, paths...)
+
+	config := watcher.Get()
+	// secrets such as the admin token are redacted when logged
+	slog.Info("loaded config", "config", config)
+
 	server, err := config.NewServer()
 	if err != nil {
 		log.Fatal(err)
 	}
 
+	server.Mux = http.NewServeMux()
+
+	// pprof and the config, with the admin token redacted, are only
+	// served to requests with the admin token
+	if config.AdminToken != "" {
+		admin := http.NewServeMux()
+		admin.HandleFunc("/debug/pprof/", pprof.Index)
+		admin.HandleFunc("/debug/pprof/cmdline", pprof.Cmdline)
+		admin.HandleFunc("/debug/pprof/profile", pprof.Profile)
+		admin.HandleFunc("/debug/pprof/symbol", pprof.Symbol)
+		admin.HandleFunc("/debug/pprof/trace", pprof.Trace)
+		admin.Handle("/debug/config", NewConfigHandler(watcher.Loaded))
+		server.Mux.Handle("/debug/", requireToken(config.AdminToken, admin))
+	}
+
 	// server.Serve?
 	log.Println(server)
 }
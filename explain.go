@@ -0,0 +1,36 @@
+package main
+
+import (
+	"io"
+)
+
+// writeSources writes the Load and Explain funcs of our root type,
+// returning the sources of every key with or instead of the config.
+func (b *StructBuilder) writeSources(w io.Writer) {
+	if !b.rootType {
+		return
+	}
+
+	b.templates.Use("Explain")
+	writeF(
+		w,
+		`// Load%[1]v is New%[1]vFrom also reporting where each key loaded its
+		// value from, keys that were never loaded are "not loaded".
+		func Load%[1]v(l Lookuper) (*%[1]v, FieldSources, error) {
+			ld := %[2]v
+			c, err := load%[1]v(ld)
+			return c, explain(%[1]vSchema, ld.Sources), err
+		}
+
+		// Explain%[1]v reports where each key of %[1]v loads its value from
+		// in l, keys that failed are reported as well as the error.
+		func Explain%[1]v(l Lookuper) (FieldSources, error) {
+			_, sources, err := Load%[1]v(l)
+			return sources, err
+		}
+
+		`,
+		b.name,
+		b.loaderExpr("l"),
+	)
+}
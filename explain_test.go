@@ -0,0 +1,58 @@
+package main
+
+import (
+	"testing"
+)
+
+func TestGeneratedExplain(t *testing.T) {
+	got := runGenerated(t, `package main
+
+type Config struct {
+	Host  string `+"`default:\"localhost\"`"+`
+	Port  int
+	Addr  string `+"`default:\"${HOST}:${PORT}\"`"+`
+	Token string `+"`secret:\"true\"`"+`
+	Level string `+"`default:\"info\" aliases:\"LOG_LEVEL\"`"+`
+}
+`, GenConfig{With: []string{"watcher"}}, `package main
+
+import (
+	"fmt"
+)
+
+func main() {
+	env := NamedLookup{Name: "file .env", Lookuper: MapLookup{"PORT": "8080", "TOKEN": "hunter2", "LOG_LEVEL": "debug"}}
+	c, sources, err := LoadConfig(env)
+	fmt.Println(c.Addr, err)
+	for _, fs := range sources {
+		fmt.Printf("%v=%v %v %v", fs.Key, fs.Value, fs.Source, fs.GoField)
+		if fs.Alias != "" {
+			fmt.Print(" alias ", fs.Alias)
+		}
+		fmt.Println()
+	}
+
+	// keys that failed are still explained
+	sources, err = ExplainConfig(MapLookup{"PORT": "http"})
+	fmt.Println(sources[1].Value, sources[1].Source, err != nil)
+
+	// the watcher explains the config it currently holds
+	w, err := NewConfigWatcher(func() (*Config, FieldSources, error) {
+		return LoadConfig(env)
+	})
+	fmt.Println(w.Explain()[1].Source, err)
+}
+`)
+
+	want := `localhost:8080 <nil>
+HOST=localhost default Host
+PORT=8080 file .env Port
+ADDR=localhost:8080 default Addr
+TOKEN=[REDACTED] file .env Token
+LEVEL=debug file .env Level alias LOG_LEVEL
+http lookup true
+file .env <nil>`
+	if got != want {
+		t.Fatalf("got:\n%v\nwant:\n%v", got, want)
+	}
+}
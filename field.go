@@ -13,20 +13,37 @@ type Field struct {
 	typeName      string
 	docs          string
 	defaultValue  string
+	hasDefault    bool
 	envKey        string
 	required      bool
 	slice         bool
 	customType    bool
+	pointer       bool
 	rootTypeField bool
+	rootPrefix    string
 	buildType     string
-	hasTypeField bool
+	loadIf        string
+	aggregate     bool
+	structName    string
+	secret        bool
+	noReload      bool
+	aliases       []string
+	deprecated    bool
+	goPath        string
+	inline        bool
+	inlinePrefix  string
+	checks        []Check
+	requiredIf    string
+	requiredWith  string
+	exclusive     string
+	compares      [][2]string
 
 	imports map[string]string
 
 	queue       *QueueCache
-	parsers     *ParserCache
 	errs        *ErrorCache
 	importCache *ImportCache
+	templates   *TemplateCache
 }
 
 func NewField(
@@ -35,9 +52,9 @@ func NewField(
 	imports map[string]string,
 	rootType bool,
 	queue *QueueCache,
-	parsers *ParserCache,
 	errs *ErrorCache,
 	importCache *ImportCache,
+	templates *TemplateCache,
 ) (*Field, error) {
 	f := &Field{
 		defaultValue:  "", // default should be empty
@@ -47,9 +64,9 @@ func NewField(
 		imports:       imports,
 		rootTypeField: rootType,
 		queue:         queue,
-		parsers:       parsers,
 		errs:          errs,
 		importCache:   importCache,
+		templates:     templates,
 	}
 
 	switch fieldType := field.Type.(type) {
@@ -59,13 +76,22 @@ func NewField(
 			f.customType = true
 		}
 	case *ast.ArrayType:
-		rootType := fieldType.Elt.(*ast.Ident)
-		f.typeName = rootType.Name
+		elemType, ok := typeNameOf(fieldType.Elt)
+		if !ok {
+			return f, fmt.Errorf("unknown slice element type: %T for field: '%v'", fieldType.Elt, fieldName(field))
+		}
+
+		f.typeName = elemType
 		f.slice = true
 	case *ast.StarExpr:
-		rootType := fieldType.X.(*ast.Ident)
+		rootType, ok := fieldType.X.(*ast.Ident)
+		if !ok {
+			return f, fmt.Errorf("unknown pointer type: %T for field: '%v'", fieldType.X, fieldName(field))
+		}
+
 		f.typeName = rootType.Name
 		f.required = false
+		f.pointer = true
 		if _, found := pkgTypes.DocTypes[f.typeName]; found {
 			f.customType = true
 		}
@@ -74,81 +100,164 @@ func NewField(
 		f.typeName = fmt.Sprintf("%v.%v", fieldType.X, rootType.Name)
 		logLine("field type:", f.typeName)
 	default:
-		return f, fmt.Errorf("unknown field type: %T for field: '%v'", fieldType, field.Names[0])
+		return f, fmt.Errorf("unknown field type: %T for field: '%v'", fieldType, fieldName(field))
 	}
 
 	// this checks for nameless variables that inherit the type name
-	if len(field.Names) > 0 {
+	embedded := len(field.Names) == 0
+	if !embedded {
 		f.varName = field.Names[0].Name
 	} else {
 		f.varName = f.typeName
 	}
 
+	f.goPath = f.varName
 	f.envKey = varNameToKey(f.varName)
+	f.deprecated = f.isDeprecated()
+	f.secret = !f.customType && isSecretName(f.varName)
+
+	// embedded struct values are promoted like go does, unless an env
+	// name is given in which case they stay nested under that name
+	_, isIdent := field.Type.(*ast.Ident)
+	f.inline = embedded && isIdent && f.customType
 
 	if field.Tag != nil {
 		tags := reflect.StructTag(strings.Trim(field.Tag.Value, "`"))
 		if def, ok := tags.Lookup("default"); ok {
 			f.required = false
-			f.defaultValue = fmt.Sprintf("\"%v\"", def)
+			f.defaultValue = def
+			f.hasDefault = true
 		}
 
 		if env, ok := tags.Lookup("env"); ok {
-			f.envKey = env
+			name, opts := parseEnvTag(env)
+			if name != "" {
+				f.envKey = name
+			}
+
+			switch {
+			case opts["inline"] || opts["squash"]:
+				if !isIdent || !f.customType {
+					return f, fmt.Errorf("inline requires a struct value for field: '%v'", f.varName)
+				}
+
+				f.inline = true
+				f.inlinePrefix = name
+			case name != "":
+				f.inline = false
+			}
+		}
+
+		if aliases, ok := tags.Lookup("aliases"); ok {
+			if f.customType {
+				return f, fmt.Errorf("aliases are not supported on struct field: '%v'", f.varName)
+			}
+
+			for _, alias := range strings.Split(aliases, ",") {
+				if alias = strings.TrimSpace(alias); alias != "" {
+					f.aliases = append(f.aliases, alias)
+				}
+			}
+		}
+
+		if secret, ok := tags.Lookup("secret"); ok {
+			if secret == "true" && f.customType {
+				return f, fmt.Errorf("secret is not supported on struct field: '%v', tag its fields instead", f.varName)
+			}
+
+			f.secret = secret == "true"
+		}
+
+		if reload, ok := tags.Lookup("reload"); ok {
+			f.noReload = reload == "false"
 		}
 
 		if bType, ok := tags.Lookup("buildType"); ok {
 			f.buildType = bType
 		}
+
+		if err := f.loadChecks(tags); err != nil {
+			return f, err
+		}
+
+		f.loadRuleTags(tags)
 	}
 
 	return f, nil
 }
 
-func (f *Field) Write(w io.Writer) error {
-	envKey := fmt.Sprintf("\"%v\"", f.envKey)
-	if !f.rootTypeField {
-		envKey = fmt.Sprintf("prefix + \"_%v\"", f.envKey)
+// keyExpr returns the go expression of our full env key. Keys of root
+// types are constants, while nested types can be used under any key so
+// each func they write takes the prefix of the field holding them and
+// calls the same func of their own nested types with our key.
+func (f *Field) keyExpr() string {
+	if f.rootTypeField {
+		return fmt.Sprintf("%q", joinKey(f.rootPrefix, f.envKey))
 	}
 
-	// if we are part of a conditional type, wrap our new in an if
-	// making sure we are actually using the type
-	if f.hasTypeField {
+	return fmt.Sprintf("prefix + \"_%v\"", f.envKey)
+}
+
+func (f *Field) Write(w io.Writer) error {
+	envKey := f.keyExpr()
+
+	// if we are only loaded conditionally, such as being one of the
+	// options of a build type, wrap our new in an if
+	if f.loadIf != "" {
 		writeF(
 			w,
-			"if c.Type == \"%v\" {\n",
-			f.envKey,
+			"if %v {\n",
+			f.loadIf,
 		)
 	}
 
 	if f.customType {
 		f.queue.Add(f.typeName)
-		writeF(
-			w,
-			"c.%v, err = New%v(%v)\nif err != nil {\n return c, err\n}",
-			f.varName,
-			f.typeName,
-			envKey,
-		)
+		if f.pointer {
+			writeF(
+				w,
+				"c.%v, err = load%v(ld, %v)",
+				f.goPath,
+				f.typeName,
+				envKey,
+			)
+			writeErrCheck(w, f.aggregate)
+		} else {
+			// struct values are loaded through a pointer and copied in
+			localName := "v" + strings.ReplaceAll(f.goPath, ".", "")
+			writeF(
+				w,
+				"%v, err := load%v(ld, %v)",
+				localName,
+				f.typeName,
+				envKey,
+			)
+			writeErrCheck(w, f.aggregate)
+			writeF(w, "\nc.%v = *%v", f.goPath, localName)
+		}
 
 	} else {
-		parserType := f.getParserFunc()
-
-		parseArgs := fmt.Sprintf("%v, %v", f.defaultValue, envKey)
-		if f.required {
-			parseArgs = envKey
+		parseFunc, convFunc, err := f.parseFuncs()
+		if err != nil {
+			return err
 		}
 
 		writeF(
 			w,
-			"c.%s, err = %v(%v)\nif err != nil {\nreturn c, err\n}",
-			f.varName,
-			parserType,
-			parseArgs,
+			"c.%s, err = %v(ld, %v, %v%v)",
+			f.goPath,
+			parseFunc,
+			envKey,
+			convFunc,
+			f.aliasArgs(),
 		)
+
+		f.writeChecks(w, envKey)
+		f.writeDescribe(w)
+		writeErrCheck(w, f.aggregate)
 	}
 
-	if f.hasTypeField {
+	if f.loadIf != "" {
 		writeF(
 			w,
 			"\n}",
@@ -160,16 +269,150 @@ func (f *Field) Write(w io.Writer) error {
 	return nil
 }
 
-func (f *Field) getParserFunc() string {
-	parser := Parser{
-		ReturnType:  f.typeName,
-		IsSlice:     f.slice,
-		IsRequired:  f.required,
-		Errs:        f.errs,
-		Imports:     f.imports,
-		ImportCache: f.importCache,
+// parseFuncs adds the parser and conv func loading our field and returns
+// their names.
+func (f *Field) parseFuncs() (string, string, error) {
+	return f.parseFuncsOf(f.required)
+}
+
+// parseFuncsOf is parseFuncs for a field required or not.
+func (f *Field) parseFuncsOf(required bool) (string, string, error) {
+	info, found := convMap[f.typeName]
+	if !found {
+		return "", "", fmt.Errorf("unknown type: %v for field: '%v'", f.typeName, f.varName)
+	}
+
+	parseFunc := "Parse"
+	if f.slice {
+		parseFunc += "Slice"
+	}
+
+	if required {
+		parseFunc += "Required"
+	} else {
+		parseFunc += "Optional"
+	}
+
+	f.templates.Use(parseFunc, info.Conv)
+	return inlineName(parseFunc), inlineName(info.Conv), nil
+}
+
+// parseEnvTag splits an env tag into the key name and any comma separated
+// options such as "inline" or "squash".
+func parseEnvTag(tag string) (string, map[string]bool) {
+	name, rest, _ := strings.Cut(tag, ",")
+	opts := make(map[string]bool)
+	for _, opt := range strings.Split(rest, ",") {
+		if opt = strings.TrimSpace(opt); opt != "" {
+			opts[opt] = true
+		}
+	}
+
+	return strings.TrimSpace(name), opts
+}
+
+// skipReason returns why a field should not be loaded, or an empty string
+// if the field should be loaded.
+func skipReason(field *ast.Field, pkgTypes *PackageTypes) string {
+	name := fieldName(field)
+
+	if field.Tag != nil {
+		tags := reflect.StructTag(strings.Trim(field.Tag.Value, "`"))
+		if env, _ := tags.Lookup("env"); env == "-" {
+			return "env tag is '-'"
+		}
+
+		if _, ok := tags.Lookup("buildType"); ok {
+			return ""
+		}
+	}
+
+	// embedded unexported structs still promote their exported fields
+	embeddedStruct := len(field.Names) == 0 && isLocalKind[*ast.StructType](field.Type, pkgTypes)
+	if !ast.IsExported(name) && !embeddedStruct {
+		return "unexported"
+	}
+
+	switch field.Type.(type) {
+	case *ast.FuncType:
+		return "func type"
+	case *ast.ChanType:
+		return "chan type"
+	case *ast.InterfaceType:
+		return "interface type"
+	}
+
+	if ident, ok := field.Type.(*ast.Ident); ok && (ident.Name == "any" || ident.Name == "error") {
+		return "interface type"
+	}
+
+	switch {
+	case isLocalKind[*ast.FuncType](field.Type, pkgTypes):
+		return "func type"
+	case isLocalKind[*ast.ChanType](field.Type, pkgTypes):
+		return "chan type"
+	case isLocalKind[*ast.InterfaceType](field.Type, pkgTypes):
+		return "interface type"
+	}
+
+	return ""
+}
+
+// isLocalKind checks if expr names a type in our package declared as T.
+func isLocalKind[T ast.Expr](expr ast.Expr, pkgTypes *PackageTypes) bool {
+	if star, ok := expr.(*ast.StarExpr); ok {
+		expr = star.X
+	}
+
+	ident, ok := expr.(*ast.Ident)
+	if !ok {
+		return false
+	}
+
+	tpe, found := pkgTypes.DocTypes[ident.Name]
+	if !found {
+		return false
+	}
+
+	for _, spec := range tpe.Decl.Specs {
+		if typeSpec, ok := spec.(*ast.TypeSpec); ok && typeSpec.Name.Name == ident.Name {
+			_, isKind := typeSpec.Type.(T)
+			return isKind
+		}
+	}
+
+	return false
+}
+
+// typeNameOf returns the name of a local or imported type, such as
+// "string" or "time.Duration".
+func typeNameOf(expr ast.Expr) (string, bool) {
+	switch t := expr.(type) {
+	case *ast.Ident:
+		return t.Name, true
+	case *ast.SelectorExpr:
+		return fmt.Sprintf("%v.%v", t.X, t.Sel.Name), true
+	}
+
+	return "", false
+}
+
+// fieldName returns the name of a field, embedded fields use their type name.
+func fieldName(field *ast.Field) string {
+	if len(field.Names) > 0 {
+		return field.Names[0].Name
+	}
+
+	switch t := field.Type.(type) {
+	case *ast.Ident:
+		return t.Name
+	case *ast.StarExpr:
+		if ident, ok := t.X.(*ast.Ident); ok {
+			return ident.Name
+		}
+	case *ast.SelectorExpr:
+		return t.Sel.Name
 	}
-	f.parsers.Add(parser.FuncName(), parser)
 
-	return parser.FuncName()
+	return ""
 }
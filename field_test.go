@@ -0,0 +1,121 @@
+package main
+
+import (
+	"testing"
+)
+
+func TestSkippedFields(t *testing.T) {
+	generated := mustGenerate(t, `package main
+
+type Config struct {
+	Port     int
+	secret   string
+	Ignored  string `+"`env:\"-\"`"+`
+	OnLoad   func()
+	Events   chan int
+	Store    interface{ Get() string }
+	Anything any
+	Failure  error
+	Hook     Handler
+	Queue    Queue
+	Backend  Backend
+	inner
+}
+
+type Handler func()
+
+type Queue chan string
+
+type Backend interface {
+	Close() error
+}
+
+// inner is unexported but still promotes its exported fields.
+type inner struct {
+	Level string
+}
+`, GenConfig{})
+
+	hasAll(t, generated,
+		`c.Port, err = parseRequired(ld, "PORT", convInt)`,
+		`c.inner.Level, err = parseRequired(ld, "LEVEL", convString)`,
+	)
+	hasNone(t, generated,
+		`"SECRET"`,
+		`"IGNORED"`,
+		`"ON_LOAD"`,
+		`"EVENTS"`,
+		`"STORE"`,
+		`"ANYTHING"`,
+		`"FAILURE"`,
+		`"HOOK"`,
+		`"QUEUE"`,
+		`"BACKEND"`,
+		`"INNER"`,
+	)
+}
+
+func TestFieldTypes(t *testing.T) {
+	generated := mustGenerate(t, `package main
+
+import (
+	"net/url"
+	"time"
+)
+
+type Config struct {
+	Waits []time.Duration
+	Ports []int
+	Wait  time.Duration
+	Site  url.URL `+"`env:\"-\"`"+`
+}
+`, GenConfig{})
+
+	hasAll(t, generated,
+		`c.Waits, err = parseSliceRequired(ld, "WAITS", convTimeDuration)`,
+		`c.Ports, err = parseSliceRequired(ld, "PORTS", convInt)`,
+		`c.Wait, err = parseRequired(ld, "WAIT", convTimeDuration)`,
+	)
+
+	for _, tc := range []struct {
+		name  string
+		field string
+		err   string
+	}{
+		{
+			name:  "unknown slice element",
+			field: "Sites []url.URL",
+			err:   "unknown type: url.URL for field: 'Sites'",
+		},
+		{
+			name:  "slice of slices",
+			field: "Matrix [][]int",
+			err:   "unknown slice element type: *ast.ArrayType for field: 'Matrix'",
+		},
+		{
+			name:  "pointer to imported type",
+			field: "Timeout *time.Duration",
+			err:   "unknown pointer type: *ast.SelectorExpr for field: 'Timeout'",
+		},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			_, err := generate(t, `package main
+
+import (
+	"net/url"
+	"time"
+)
+
+var _ url.URL
+var _ time.Duration
+
+type Config struct {
+	`+tc.field+`
+}
+`, GenConfig{})
+			if err == nil || err.Error() != tc.err {
+				t.Fatalf("expected error %q, got: %v", tc.err, err)
+			}
+		})
+	}
+}
@@ -0,0 +1,154 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"strings"
+
+	"github.com/miniscruff/genenv/envrt"
+)
+
+// flagKind is a flag library bindings can be generated for, every kind
+// is written from the same body.
+type flagKind struct {
+	// FuncSuffix follows the type name in the name of the bind func
+	FuncSuffix string
+	// Desc is written before "flag" in the doc of the bind func
+	Desc       string
+	FlagSet    string
+	ImportName string
+	ImportPath string
+	// Changed is a go expression checking if the flag name was set
+	Changed   string
+	Templates []string
+}
+
+// flagKinds are the kinds of --flags.
+var flagKinds = map[string]flagKind{
+	"pflag": {
+		FlagSet:    "*pflag.FlagSet",
+		ImportName: "pflag",
+		ImportPath: "github.com/spf13/pflag",
+		Changed:    "fs.Changed(name)",
+	},
+	"std": {
+		FuncSuffix: "Std",
+		Desc:       "standard library ",
+		FlagSet:    "*flag.FlagSet",
+		ImportName: "flag",
+		ImportPath: "flag",
+		Changed:    "stdFlagChanged(fs, name)",
+		Templates:  []string{"StdFlagChanged"},
+	},
+}
+
+// writeFlags writes adding a flag for each of our keys.
+func (b *StructBuilder) writeFlags(w io.Writer) {
+	if len(b.flags) == 0 {
+		return
+	}
+
+	b.templates.Use("FlagAdder", "FlagLookup", "FlagUsage", "FlagName")
+
+	if b.rootType {
+		writeF(w, "func add%vFlags(add flagAdder) {\n", b.name)
+	} else {
+		writeF(w, "func add%vFlags(add flagAdder, prefix string) {\n", b.name)
+	}
+
+	for _, f := range b.order {
+		if f.customType {
+			writeF(w, "add%vFlags(add, %v)\n", f.typeName, f.keyExpr())
+			continue
+		}
+
+		isBool := f.typeName == "bool" && !f.slice
+		def := f.defaultValue
+		if f.secret {
+			def = ""
+		}
+
+		// bad bool defaults fail generation once every type is built
+		if isBool {
+			v, _ := envrt.ConvBool(def)
+			def = fmt.Sprint(v)
+		}
+
+		writeF(
+			w,
+			"add(%v, %q, %q, %v)\n",
+			f.keyExpr(),
+			def,
+			f.usage(),
+			isBool,
+		)
+	}
+
+	writeF(w, "}\n\n")
+
+	if !b.rootType {
+		return
+	}
+
+	// flags are only used by this binary so a shared prefix is left off
+	nameKey := "key"
+	if b.keyPrefix != "" {
+		nameKey = fmt.Sprintf("strings.TrimPrefix(key, %vPrefix+\"_\")", b.name)
+		b.importCache.Add("strings", "strings")
+	}
+
+	for _, name := range b.flags {
+		kind := flagKinds[name]
+		b.importCache.Add(kind.ImportName, kind.ImportPath)
+		b.templates.Use(kind.Templates...)
+		writeF(
+			w,
+			`// Bind%[1]v%[2]vFlags registers a %[3]vflag for every key of %[1]v, use
+			// the returned Lookuper as the highest source once the flags are parsed.
+			func Bind%[1]v%[2]vFlags(fs %[4]v) Lookuper {
+				fl := &flagLookup{Names: make(map[string]string)}
+				add%[1]vFlags(func(key, def, usage string, isBool bool) {
+					name := FlagName(%[5]v)
+					fl.Names[key] = name
+					if isBool {
+						fs.Bool(name, def == "true", flagUsage(usage, key))
+					} else {
+						fs.String(name, def, flagUsage(usage, key))
+					}
+				})
+
+				fl.Changed = func(name string) (string, bool) {
+					if !%[6]v {
+						return "", false
+					}
+
+					return fs.Lookup(name).Value.String(), true
+				}
+
+				return fl
+			}
+
+			`,
+			b.name,
+			kind.FuncSuffix,
+			kind.Desc,
+			kind.FlagSet,
+			nameKey,
+			kind.Changed,
+		)
+	}
+}
+
+// usage returns the flag usage of a field, the first paragraph of its
+// docs on a single line.
+func (f *Field) usage() string {
+	doc, _, _ := strings.Cut(strings.TrimSpace(f.docs), "\n\n")
+	usage := strings.Join(strings.Fields(doc), " ")
+
+	// deprecated flags say so even when it is not in the first paragraph
+	if deprecation := f.deprecation(); !strings.Contains(usage, deprecation) {
+		usage = strings.TrimSpace(usage + " " + deprecation)
+	}
+
+	return usage
+}
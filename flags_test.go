@@ -0,0 +1,85 @@
+package main
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestFieldUsage(t *testing.T) {
+	for _, tc := range []struct {
+		name string
+		docs string
+		want string
+	}{
+		{name: "empty", docs: "", want: ""},
+		{name: "first paragraph", docs: "Port to listen on.\n\nMore details\nhere.\n", want: "Port to listen on."},
+		{name: "joined lines", docs: "Port to\nlisten on.\n", want: "Port to listen on."},
+		{
+			name: "deprecated paragraph",
+			docs: "Old port.\n\nDeprecated: use\nPORT instead.\n\nMore details.\n",
+			want: "Old port. Deprecated: use PORT instead.",
+		},
+		{name: "deprecated first", docs: "Deprecated: use PORT.\n", want: "Deprecated: use PORT."},
+		{name: "deprecated in first", docs: "Old port.\nDeprecated: use PORT.\n", want: "Old port. Deprecated: use PORT."},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			f := &Field{docs: tc.docs}
+			if got := f.usage(); got != tc.want {
+				t.Fatalf("got %q, want %q", got, tc.want)
+			}
+		})
+	}
+}
+
+func TestGeneratedFlags(t *testing.T) {
+	got := runGenerated(t, `package main
+
+type Config struct {
+	// Host to listen on.
+	Host  string `+"`default:\"localhost\"`"+`
+	Port  int    `+"`default:\"80\"`"+`
+	Debug bool   `+"`default:\"yes\"`"+`
+	Db    *DbConfig
+}
+
+type DbConfig struct {
+	File string `+"`default:\"data.db\"`"+`
+}
+`, GenConfig{Prefix: "APP", Flags: []string{"pflag", "std"}}, `package main
+
+import (
+	"flag"
+	"fmt"
+
+	"github.com/spf13/pflag"
+)
+
+func main() {
+	pfs := pflag.NewFlagSet("p", pflag.ContinueOnError)
+	pl := BindConfigFlags(pfs)
+	pfs.Parse([]string{"--port", "8080", "--debug=false", "--db-file", "p.db"})
+
+	c, err := NewConfigFrom(ChainLookup{pl, MapLookup{"APP_HOST": "env"}})
+	fmt.Println(c.Host, c.Port, c.Debug, c.Db.File, err)
+	fmt.Println(pfs.Lookup("host").Usage, pfs.Lookup("debug").DefValue)
+
+	sfs := flag.NewFlagSet("s", flag.ContinueOnError)
+	sl := BindConfigStdFlags(sfs)
+	sfs.Parse([]string{"-host", "std", "-debug=false"})
+
+	c, err = NewConfigFrom(ChainLookup{sl, MapLookup{"APP_PORT": "90"}})
+	fmt.Println(c.Host, c.Port, c.Debug, c.Db.File, err)
+	fmt.Println(sfs.Lookup("host").Usage, sfs.Lookup("debug").DefValue)
+}
+`)
+
+	want := strings.Join([]string{
+		"env 8080 false p.db <nil>",
+		"Host to listen on. (env APP_HOST) true",
+		"std 90 false data.db <nil>",
+		"Host to listen on. (env APP_HOST) true",
+	}, "\n")
+	if got != want {
+		t.Fatalf("got:\n%v\nwant:\n%v", got, want)
+	}
+}
@@ -0,0 +1,199 @@
+package main
+
+import (
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// generate writes src as config.go of a new package main and runs GenEnv
+// on it, returning the generated source.
+func generate(t *testing.T, src string, cfg GenConfig) (string, error) {
+	t.Helper()
+
+	dir := t.TempDir()
+	writeSource(t, dir, "config.go", src)
+
+	return generateIn(t, dir, cfg)
+}
+
+// generateIn runs GenEnv on the package main in dir, returning the
+// generated source written to config_gen.go unless another file is given.
+func generateIn(t *testing.T, dir string, cfg GenConfig) (string, error) {
+	t.Helper()
+
+	cfg.PackageName = "main"
+	cfg.FileDir = dir
+	if len(cfg.ConfigTypes) == 0 {
+		cfg.ConfigTypes = []string{"Config"}
+	}
+
+	if cfg.GoOutputFile == "" {
+		cfg.GoOutputFile = filepath.Join(dir, "config_gen.go")
+	}
+
+	if err := GenEnv(cfg); err != nil {
+		return "", err
+	}
+
+	generated, err := os.ReadFile(cfg.GoOutputFile)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return string(generated), nil
+}
+
+func writeSource(t *testing.T, dir, name, src string) {
+	t.Helper()
+
+	if err := os.WriteFile(filepath.Join(dir, name), []byte(src), 0o644); err != nil {
+		t.Fatal(err)
+	}
+}
+
+// mustGenerate is generate failing the test on any error.
+func mustGenerate(t *testing.T, src string, cfg GenConfig) string {
+	t.Helper()
+
+	generated, err := generate(t, src, cfg)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return generated
+}
+
+// runGenerated generates src as a package main along with mainSrc as its
+// main.go, then runs it and returns its trimmed combined output.
+func runGenerated(t *testing.T, src string, cfg GenConfig, mainSrc string) string {
+	t.Helper()
+
+	if testing.Short() {
+		t.Skip("runs the go tool")
+	}
+
+	dir := t.TempDir()
+	writeSource(t, dir, "config.go", src)
+	if _, err := generateIn(t, dir, cfg); err != nil {
+		t.Fatal(err)
+	}
+
+	repo, err := filepath.Abs(".")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// the runtime and flag packages resolve to this repo and its deps
+	goSum, err := os.ReadFile("go.sum")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	writeSource(t, dir, "go.sum", string(goSum))
+	writeSource(t, dir, "go.mod", "module cfgtest\n\ngo 1.21\n\n"+
+		"require github.com/miniscruff/genenv v0.0.0\n\n"+
+		"replace github.com/miniscruff/genenv => "+repo+"\n")
+	writeSource(t, dir, "main.go", mainSrc)
+
+	cmd := exec.Command("go", "run", ".")
+	cmd.Dir = dir
+	cmd.Env = append(os.Environ(), "GOFLAGS=-mod=mod")
+	out, err := cmd.CombinedOutput()
+	if err != nil {
+		t.Fatalf("go run: %v\n%s", err, out)
+	}
+
+	return strings.TrimSpace(string(out))
+}
+
+// hasAll fails the test for each of wants missing from generated.
+func hasAll(t *testing.T, generated string, wants ...string) {
+	t.Helper()
+
+	for _, want := range wants {
+		if !strings.Contains(generated, want) {
+			t.Errorf("expected %q in:\n%v", want, generated)
+		}
+	}
+}
+
+// hasNone fails the test for each of unwanted found in generated.
+func hasNone(t *testing.T, generated string, unwanted ...string) {
+	t.Helper()
+
+	for _, u := range unwanted {
+		if strings.Contains(generated, u) {
+			t.Errorf("expected no %q in:\n%v", u, generated)
+		}
+	}
+}
+
+// TestExampleServer generates the example server again and builds it, so
+// every option it uses produces code that compiles together.
+func TestExampleServer(t *testing.T) {
+	if testing.Short() {
+		t.Skip("runs the go tool")
+	}
+
+	const exampleDir = "examples/server"
+
+	// regenerate next to a copy of the sources, so the example is left as is
+	dir := t.TempDir()
+	entries, err := os.ReadDir(exampleDir)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for _, entry := range entries {
+		if entry.IsDir() || filepath.Ext(entry.Name()) != ".go" || entry.Name() == "config_gen.go" {
+			continue
+		}
+
+		src, err := os.ReadFile(filepath.Join(exampleDir, entry.Name()))
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if err := os.WriteFile(filepath.Join(dir, entry.Name()), src, 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	// keep in sync with the go:generate directive of the example
+	err = GenEnv(GenConfig{
+		PackageName:   "main",
+		FileDir:       dir,
+		ConfigTypes:   []string{"Config"},
+		EnvOutputFile: filepath.Join(dir, ".env.example"),
+		Aggregate:     true,
+		Flags:         []string{"pflag"},
+		With:          []string{"watcher", "handler", "compare"},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for _, name := range []string{"config_gen.go", ".env.example"} {
+		generated, err := os.ReadFile(filepath.Join(dir, name))
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		committed, err := os.ReadFile(filepath.Join(exampleDir, name))
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if string(generated) != string(committed) {
+			t.Fatalf("%v/%v is out of date, run go generate", exampleDir, name)
+		}
+	}
+
+	out, err := exec.Command("go", "build", "-o", os.DevNull, "./"+exampleDir).CombinedOutput()
+	if err != nil {
+		t.Fatalf("go build: %v\n%s", err, out)
+	}
+}
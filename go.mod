@@ -1,6 +1,6 @@
 module github.com/miniscruff/genenv
 
-go 1.19
+go 1.21
 
 require (
 	github.com/mattn/go-sqlite3 v1.14.16
@@ -0,0 +1,34 @@
+package main
+
+import (
+	"io"
+)
+
+// writeHandler writes the http handler serving our root type, built from
+// its schema, ToEnv and the sources returned while loading.
+func (b *StructBuilder) writeHandler(w io.Writer) {
+	if !b.rootType || !b.writes("handler") {
+		return
+	}
+
+	b.templates.Use("ConfigHandler", "ConfigEntries")
+	b.importCache.Add("http", "net/http")
+	writeF(
+		w,
+		`// New%[1]vHandler serves the config and sources returned by get as
+		// JSON, or as HTML to browsers, with the doc of each key and secret
+		// values redacted. Keys, docs and sources are still exposed, so only
+		// mount it on an internal listener or behind authentication:
+		//
+		//	admin.Handle("/debug/config", New%[1]vHandler(watcher.Loaded))
+		func New%[1]vHandler(get func() (*%[1]v, FieldSources)) http.Handler {
+			return configHandler(%[1]q, func() []ConfigEntry {
+				c, sources := get()
+				return configEntries(%[1]vSchema, c.ToEnv(), sources)
+			})
+		}
+
+		`,
+		b.name,
+	)
+}
@@ -0,0 +1,54 @@
+package main
+
+import (
+	"testing"
+)
+
+func TestHandlerOptIn(t *testing.T) {
+	generated := mustGenerate(t, "package main\n\ntype Config struct {\nPort int `default:\"80\"`\n}\n", GenConfig{})
+	hasNone(t, generated, "NewConfigHandler", "net/http")
+}
+
+func TestGeneratedHandler(t *testing.T) {
+	got := runGenerated(t, `package main
+
+type Config struct {
+	Host  string `+"`default:\"localhost\"`"+`
+	Port  int
+	Token string `+"`secret:\"true\"`"+`
+}
+`, GenConfig{With: []string{"handler"}}, `package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http/httptest"
+)
+
+func main() {
+	c, sources, err := LoadConfig(NamedLookup{Name: "file .env", Lookuper: MapLookup{"PORT": "8080", "TOKEN": "hunter2"}})
+	if err != nil {
+		panic(err)
+	}
+
+	rec := httptest.NewRecorder()
+	NewConfigHandler(func() (*Config, FieldSources) { return c, sources }).ServeHTTP(rec, httptest.NewRequest("GET", "/", nil))
+
+	var data struct {
+		Entries []ConfigEntry
+	}
+	if err := json.Unmarshal(rec.Body.Bytes(), &data); err != nil {
+		panic(err)
+	}
+
+	for _, e := range data.Entries {
+		fmt.Printf("%v=%v %v\n", e.Key, e.Value, e.Source)
+	}
+}
+`)
+
+	want := "HOST=localhost default\nPORT=8080 file .env\nTOKEN=[REDACTED] file .env"
+	if got != want {
+		t.Fatalf("got:\n%v\nwant:\n%v", got, want)
+	}
+}
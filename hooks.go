@@ -0,0 +1,142 @@
+package main
+
+import (
+	"fmt"
+	"go/ast"
+	"go/doc"
+	"io"
+	"strings"
+)
+
+// ValidateCall is a call of a Validate hook and the go field it is
+// called on, failures are wrapped in a field error naming it.
+type ValidateCall struct {
+	Call    string
+	GoField string
+	Doc     string
+}
+
+// resolveValidateHooks finds the Validate hooks of our inline types and
+// our own type. Inline types are validated through their field as their
+// hooks are only promoted to us when we do not have one.
+func (b *StructBuilder) resolveValidateHooks() error {
+	for _, path := range b.inlinePaths {
+		tpe := b.pkgTypes.DocTypes[b.inlineTypes[path]]
+		call, err := b.validateCall(tpe, "c."+path)
+		if err != nil {
+			return err
+		}
+
+		if call != "" {
+			b.validateCalls = append(b.validateCalls, ValidateCall{
+				Call:    call,
+				GoField: b.name + "." + path,
+				Doc:     strings.TrimSpace(tpe.Doc),
+			})
+		}
+	}
+
+	call, err := b.validateCall(b.us, "c")
+	if err != nil {
+		return err
+	}
+
+	if call != "" {
+		b.validateCalls = append(b.validateCalls, ValidateCall{
+			Call:    call,
+			GoField: b.name,
+			Doc:     strings.TrimSpace(b.us.Doc),
+		})
+	}
+
+	return nil
+}
+
+// validateCall returns how to call the Validate hook of tpe on recv, or
+// an empty string if it does not have one. Hooks with any signature other
+// than Validate() error or Validate(context.Context) error are an error.
+func (b *StructBuilder) validateCall(tpe *doc.Type, recv string) (string, error) {
+	for _, method := range tpe.Methods {
+		// promoted methods are found on the type they are declared on
+		if method.Name != "Validate" || method.Level > 0 {
+			continue
+		}
+
+		funcType := method.Decl.Type
+		validResults := funcType.Results.NumFields() == 1 && isIdentNamed(funcType.Results.List[0].Type, "error")
+
+		switch {
+		case validResults && funcType.Params.NumFields() == 0:
+			return recv + ".Validate()", nil
+		case validResults && funcType.Params.NumFields() == 1 && b.isContextType(funcType.Params.List[0].Type):
+			b.importCache.Add("context", "context")
+			return recv + ".Validate(context.Background())", nil
+		default:
+			return "", fmt.Errorf(
+				"Validate method of type: '%v' must be func() error or func(context.Context) error",
+				tpe.Name,
+			)
+		}
+	}
+
+	return "", nil
+}
+
+// isContextType checks if expr is context.Context under any import name.
+func (b *StructBuilder) isContextType(expr ast.Expr) bool {
+	sel, ok := expr.(*ast.SelectorExpr)
+	if !ok || sel.Sel.Name != "Context" {
+		return false
+	}
+
+	pkg, ok := sel.X.(*ast.Ident)
+	return ok && b.imports[pkg.Name] == "context"
+}
+
+// writeValidateHook writes calling the Validate hooks of our type and its
+// inline types. Nested types are loaded first so they are always
+// validated before us.
+func (b *StructBuilder) writeValidateHook(w io.Writer) {
+	if len(b.validateCalls) == 0 {
+		return
+	}
+
+	logLine("found validate hooks on:", b.name)
+
+	// roots are loaded under their prefix, nested types under the key of
+	// the field holding them
+	keyExpr := "prefix"
+	if b.rootType {
+		keyExpr = fmt.Sprintf("%q", b.keyPrefix)
+	}
+
+	b.templates.Use("FieldError")
+
+	// skip validating if any of our fields already failed
+	if b.aggregate {
+		writeF(w, "if len(errs) == 0 {\n")
+	}
+
+	for _, call := range b.validateCalls {
+		writeF(
+			w,
+			"if err := %v; err != nil {\n%v\n}\n",
+			call.Call,
+			failStmt(b.aggregate, fmt.Sprintf(
+				"&FieldError{Key: %v, GoField: %q, Doc: %q, Err: err}",
+				keyExpr,
+				call.GoField,
+				call.Doc,
+			)),
+		)
+	}
+
+	if b.aggregate {
+		writeF(w, "}\n")
+	}
+}
+
+func isIdentNamed(expr ast.Expr, name string) bool {
+	ident, ok := expr.(*ast.Ident)
+	return ok && ident.Name == name
+}
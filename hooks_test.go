@@ -0,0 +1,168 @@
+package main
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestValidateHookSignature(t *testing.T) {
+	for _, tc := range []struct {
+		name    string
+		hook    string
+		wantErr string
+	}{
+		{name: "none", hook: ""},
+		{name: "no args", hook: "func (c *Config) Validate() error { return nil }"},
+		{name: "value receiver", hook: "func (c Config) Validate() error { return nil }"},
+		{name: "context", hook: "func (c *Config) Validate(ctx context.Context) error { return nil }"},
+		{
+			name:    "no result",
+			hook:    "func (c *Config) Validate() {}",
+			wantErr: "Validate method of type: 'Config' must be func() error or func(context.Context) error",
+		},
+		{
+			name:    "bool result",
+			hook:    "func (c *Config) Validate() bool { return true }",
+			wantErr: "Validate method of type: 'Config' must be",
+		},
+		{
+			name:    "two results",
+			hook:    "func (c *Config) Validate() (bool, error) { return true, nil }",
+			wantErr: "Validate method of type: 'Config' must be",
+		},
+		{
+			name:    "other param",
+			hook:    "func (c *Config) Validate(strict bool) error { return nil }",
+			wantErr: "Validate method of type: 'Config' must be",
+		},
+		{
+			name:    "two params",
+			hook:    "func (c *Config) Validate(ctx context.Context, strict bool) error { return nil }",
+			wantErr: "Validate method of type: 'Config' must be",
+		},
+		{
+			name:    "inline type",
+			hook:    "func (c *Inner) Validate(strict bool) error { return nil }",
+			wantErr: "Validate method of type: 'Inner' must be",
+		},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			src := "package main\n\nimport \"context\"\n\n" +
+				"type Config struct {\nInner\nPort int `default:\"80\"`\n}\n\n" +
+				"type Inner struct {\nHost string `default:\"localhost\"`\n}\n\n" + tc.hook + "\n"
+
+			_, err := generate(t, src, GenConfig{})
+			if tc.wantErr == "" {
+				if err != nil {
+					t.Fatal(err)
+				}
+
+				return
+			}
+
+			if err == nil || !strings.Contains(err.Error(), tc.wantErr) {
+				t.Fatalf("expected %q, got %v", tc.wantErr, err)
+			}
+		})
+	}
+}
+
+func TestGeneratedInlineValidateHooks(t *testing.T) {
+	got := runGenerated(t, `package main
+
+import (
+	"context"
+	"fmt"
+)
+
+var calls []string
+
+type Config struct {
+	Logging
+	Db   DbConfig `+"`env:\"DB,squash\"`"+`
+	Port int      `+"`default:\"80\"`"+`
+}
+
+type Logging struct {
+	Level string `+"`default:\"info\"`"+`
+}
+
+func (l Logging) Validate(ctx context.Context) error {
+	calls = append(calls, "Logging")
+	if l.Level == "trace" {
+		return fmt.Errorf("trace is not allowed")
+	}
+
+	return nil
+}
+
+type DbConfig struct {
+	File string `+"`default:\"db.sqlite\"`"+`
+}
+
+func (d *DbConfig) Validate() error {
+	calls = append(calls, "DbConfig")
+	return nil
+}
+
+func (c *Config) Validate() error {
+	calls = append(calls, "Config")
+	return nil
+}
+`, GenConfig{}, `package main
+
+import (
+	"fmt"
+)
+
+func main() {
+	c, err := NewConfigFrom(MapLookup{})
+	fmt.Println(c.Db.File, calls, err)
+
+	calls = nil
+	_, err = NewConfigFrom(MapLookup{"LEVEL": "trace"})
+	fmt.Println(calls, err)
+}
+`)
+
+	// inline types are validated before the type holding them
+	want := "db.sqlite [Logging DbConfig Config] <nil>\n[Logging] trace is not allowed (Config.Logging)"
+	if got != want {
+		t.Fatalf("got:\n%v\nwant:\n%v", got, want)
+	}
+}
+
+func TestGeneratedValidateHookErrors(t *testing.T) {
+	src := `package main
+
+// Config of the app.
+type Config struct {
+	Db *DbConfig
+}
+
+// DbConfig of the store.
+type DbConfig struct {
+	File string
+}
+
+func (d *DbConfig) Validate() error {
+	return nil
+}
+
+func (c *Config) Validate() error {
+	return nil
+}
+`
+
+	// hook failures name the type and the key it was loaded under
+	generated := mustGenerate(t, src, GenConfig{Prefix: "APP"})
+	hasAll(t, generated,
+		`return c, &FieldError{Key: "APP", GoField: "Config", Doc: "Config of the app.", Err: err}`,
+		`return c, &FieldError{Key: prefix, GoField: "DbConfig", Doc: "DbConfig of the store.", Err: err}`,
+	)
+
+	generated = mustGenerate(t, src, GenConfig{})
+	hasAll(t, generated,
+		`return c, &FieldError{Key: "", GoField: "Config", Doc: "Config of the app.", Err: err}`,
+	)
+}
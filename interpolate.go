@@ -0,0 +1,93 @@
+package main
+
+import (
+	"fmt"
+	"strings"
+)
+
+// parseRefs returns the keys referenced by a value, skipping escaped
+// references and any unclosed one.
+func parseRefs(v string) []string {
+	var refs []string
+	for {
+		i := strings.Index(v, "${")
+		if i < 0 {
+			return refs
+		}
+
+		if i > 0 && v[i-1] == '$' {
+			v = v[i+2:]
+			continue
+		}
+
+		end := strings.IndexByte(v[i+2:], '}')
+		if end < 0 {
+			return refs
+		}
+
+		refs = append(refs, v[i+2:i+2+end])
+		v = v[i+3+end:]
+	}
+}
+
+// checkDefaultRefs walks every key of our root type and fails if a default
+// tag references a key our root type does not have, or if the default tags
+// reference each other in a cycle, as those can never load unless the
+// environment breaks the cycle. References are relative to rootPrefix.
+func checkDefaultRefs(builders map[string]*StructBuilder, rootType, rootPrefix string) error {
+	known := make(map[string]bool)
+	defaults := make(map[string]string)
+	var keys []string
+	for _, rk := range collectKeys(builders, rootType, rootPrefix) {
+		known[rk.key] = true
+		if rk.field.hasDefault {
+			defaults[rk.key] = rk.field.defaultValue
+			keys = append(keys, rk.key)
+		}
+	}
+
+	const (
+		unvisited = iota
+		visiting
+		done
+	)
+	state := make(map[string]int)
+
+	var visit func(key string, path []string) error
+	visit = func(key string, path []string) error {
+		switch state[key] {
+		case visiting:
+			return fmt.Errorf("default tags reference each other in a cycle: %v", strings.Join(append(path, key), " -> "))
+		case done:
+			return nil
+		}
+
+		state[key] = visiting
+		for _, ref := range parseRefs(defaults[key]) {
+			ref = joinKey(rootPrefix, ref)
+			if !known[ref] {
+				return fmt.Errorf("default of %v references an unknown key: %v", key, ref)
+			}
+
+			if _, found := defaults[ref]; !found {
+				logLine("default of", key, "references a key without a default:", ref)
+				continue
+			}
+
+			if err := visit(ref, append(path, key)); err != nil {
+				return err
+			}
+		}
+		state[key] = done
+
+		return nil
+	}
+
+	for _, key := range keys {
+		if err := visit(key, nil); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
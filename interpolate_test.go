@@ -0,0 +1,170 @@
+package main
+
+import (
+	"slices"
+	"strings"
+	"testing"
+)
+
+func TestParseRefs(t *testing.T) {
+	for _, tc := range []struct {
+		value string
+		want  []string
+	}{
+		{value: "plain"},
+		{value: "${HOST}", want: []string{"HOST"}},
+		{value: "http://${HOST}:${PORT}/", want: []string{"HOST", "PORT"}},
+		{value: "$${HOST}"},
+		{value: "$${HOST}${PORT}", want: []string{"PORT"}},
+		{value: "$HOST"},
+		{value: "${HOST"},
+		{value: "${HOST}${PORT", want: []string{"HOST"}},
+		{value: "${}", want: []string{""}},
+	} {
+		if got := parseRefs(tc.value); !slices.Equal(got, tc.want) {
+			t.Errorf("parseRefs(%q) = %q, want %q", tc.value, got, tc.want)
+		}
+	}
+}
+
+func TestCheckDefaultRefs(t *testing.T) {
+	for _, tc := range []struct {
+		name    string
+		fields  string
+		cfg     GenConfig
+		wantErr string
+	}{
+		{
+			name:   "nested",
+			fields: "Host string `default:\"localhost\"`\nAddr string `default:\"${HOST}:80\"`\nUrl string `default:\"http://${ADDR}/\"`",
+		},
+		{
+			name:   "escaped",
+			fields: "Addr string `default:\"$${ADDR}\"`",
+		},
+		{
+			name:   "env only",
+			fields: "Host string\nAddr string `default:\"${HOST}:80\"`",
+		},
+		{
+			name:    "unknown",
+			fields:  "Addr string `default:\"${HOST}:80\"`",
+			wantErr: "default of ADDR references an unknown key: HOST",
+		},
+		{
+			name:   "prefix",
+			fields: "Host string `default:\"localhost\"`\nAddr string `default:\"${HOST}:80\"`",
+			cfg:    GenConfig{Prefix: "APP"},
+		},
+		{
+			name:    "prefixed ref",
+			fields:  "Host string `default:\"localhost\"`\nAddr string `default:\"${APP_HOST}:80\"`",
+			cfg:     GenConfig{Prefix: "APP"},
+			wantErr: "default of APP_ADDR references an unknown key: APP_APP_HOST",
+		},
+		{
+			name:    "prefix cycle",
+			fields:  "Ping string `default:\"${PONG}\"`\nPong string `default:\"x${PING}\"`",
+			cfg:     GenConfig{Prefix: "APP"},
+			wantErr: "cycle: APP_PING -> APP_PONG -> APP_PING",
+		},
+		{
+			name:    "self",
+			fields:  "Addr string `default:\"${ADDR}\"`",
+			wantErr: "cycle: ADDR -> ADDR",
+		},
+		{
+			name:    "cycle",
+			fields:  "Ping string `default:\"${PONG}\"`\nPong string `default:\"x${PING}\"`",
+			wantErr: "cycle: PING -> PONG -> PING",
+		},
+		{
+			name:    "nested type",
+			fields:  "Db DbConfig\nName string `default:\"${DB_URL}\"`\n}\n\ntype DbConfig struct {\nUrl string `default:\"${NAME}\"`",
+			wantErr: "cycle: DB_URL -> NAME -> DB_URL",
+		},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			_, err := generate(t, "package main\n\ntype Config struct {\n"+tc.fields+"\n}\n", tc.cfg)
+			if tc.wantErr == "" {
+				if err != nil {
+					t.Fatal(err)
+				}
+
+				return
+			}
+
+			if err == nil || !strings.Contains(err.Error(), tc.wantErr) {
+				t.Fatalf("expected %q, got %v", tc.wantErr, err)
+			}
+		})
+	}
+}
+
+func TestGeneratedRefs(t *testing.T) {
+	got := runGenerated(t, `package main
+
+type Config struct {
+	Host    string `+"`default:\"localhost\"`"+`
+	Port    int    `+"`default:\"80\"`"+`
+	Addr    string `+"`default:\"${HOST}:${PORT}\"`"+`
+	Url     string `+"`default:\"http://${ADDR}/$${PATH}\"`"+`
+	Name    string `+"`default:\"${MISSING}\"`"+`
+	Missing string
+}
+`, GenConfig{}, `package main
+
+import (
+	"errors"
+	"fmt"
+)
+
+func main() {
+	c, err := NewConfigFrom(MapLookup{"PORT": "8080", "MISSING": "${HOST}"})
+	fmt.Println(c.Url, c.Name, err)
+
+	_, err = NewConfigFrom(MapLookup{})
+	fmt.Println(errors.Is(err, ErrUnresolvedRef))
+
+	_, err = NewConfigFrom(MapLookup{"HOST": "${URL}"})
+	fmt.Println(errors.Is(err, ErrRefCycle))
+}
+`)
+
+	want := "http://localhost:8080/${PATH} localhost <nil>\ntrue\ntrue"
+	if got != want {
+		t.Fatalf("got:\n%v\nwant:\n%v", got, want)
+	}
+}
+
+func TestGeneratedPrefixRefs(t *testing.T) {
+	got := runGenerated(t, `package main
+
+type Config struct {
+	Host string `+"`default:\"localhost\"`"+`
+	Addr string `+"`default:\"${HOST}:80\"`"+`
+}
+`, GenConfig{Prefix: "APP"}, `package main
+
+import (
+	"fmt"
+)
+
+func main() {
+	c, err := NewConfigFrom(MapLookup{"HOST": "unprefixed"})
+	fmt.Println(c.Addr, err)
+
+	c, err = NewConfigFrom(MapLookup{"HOST": "unprefixed", "APP_HOST": "example.com"})
+	fmt.Println(c.Addr, err)
+
+	c, err = DefaultConfig()
+	fmt.Println(c.Addr, err)
+}
+`)
+
+	// references stay relative to the prefix
+	want := "localhost:80 <nil>\nexample.com:80 <nil>\nlocalhost:80 <nil>"
+	if got != want {
+		t.Fatalf("got:\n%v\nwant:\n%v", got, want)
+	}
+}
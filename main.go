@@ -11,6 +11,7 @@ import (
 	"io/fs"
 	"log"
 	"os"
+	"slices"
 	"strings"
 
 	flag "github.com/spf13/pflag"
@@ -19,26 +20,56 @@ import (
 type PackageTypes struct {
 	DocTypes map[string]*doc.Type
 	Imports  map[string]string
+	// Declared maps each top level name to its declaration, methods are
+	// named Type.Method
+	Declared map[string]Decl
+	// Options holds the generation options of each file written by
+	// genenv, by file name
+	Options map[string]string
 }
 
-var logLine func(args ...any)
+var logLine = func(args ...any) {}
+
+// generatedHeader marks the files we write, only declarations in these
+// files are shared between runs.
+const generatedHeader = "// Code generated by genenv. DO NOT EDIT."
+
+// optionsHeader is followed by the options changing the shared
+// declarations of a generated file, runs with other options can not reuse
+// them.
+const optionsHeader = "// genenv options:"
+
+// withOutputs are the optional outputs enabled with --with.
+var withOutputs = []string{"watcher", "handler", "compare"}
 
 func main() {
 	var (
-		pkgName    string
-		fileDir    string
-		configType string
-		genFile    string
-		verbose    bool
-		envFile    string
+		pkgName     string
+		fileDir     string
+		configTypes []string
+		genFile     string
+		verbose     bool
+		envFile     string
+		aggregate   bool
+		strict      bool
+		flagKinds   []string
+		with        []string
+		prefix      string
+		runtime     bool
 	)
 
 	flag.StringVarP(&pkgName, "package", "p", "", "Name of config type, defaults to dir")
 	flag.StringVarP(&fileDir, "dir", "d", "", "Directory of our config file")
-	flag.StringVarP(&configType, "config", "c", "", "Name of config type")
+	flag.StringSliceVarP(&configTypes, "config", "c", nil, "Name of config types, comma separated")
 	flag.StringVarP(&genFile, "file", "f", "", "Name of generated file to write to")
 	flag.BoolVarP(&verbose, "verbose", "v", false, "verbose logging")
 	flag.StringVarP(&envFile, "env", "e", "", "Name of file to write env example to")
+	flag.BoolVarP(&aggregate, "aggregate", "a", false, "Collect every config error instead of returning the first")
+	flag.BoolVarP(&strict, "strict", "s", false, "Fail loading if unknown env vars are found under our prefixes")
+	flag.StringSliceVar(&flagKinds, "flags", nil, "Generate flag bindings, any of: pflag, std")
+	flag.StringSliceVar(&with, "with", nil, "Generate optional helpers, any of: "+strings.Join(withOutputs, ", "))
+	flag.StringVar(&prefix, "prefix", "", "Prefix every key of the config type, overrides a genenv:prefix directive")
+	flag.BoolVar(&runtime, "runtime", false, "Use the shared envrt package instead of writing helpers into the generated file")
 
 	flag.Parse()
 
@@ -49,10 +80,16 @@ func main() {
 	cfg := GenConfig{
 		PackageName:   pkgName,
 		FileDir:       fileDir,
-		ConfigType:    configType,
+		ConfigTypes:   configTypes,
 		GoOutputFile:  genFile,
 		EnvOutputFile: envFile,
 		Verbose:       verbose,
+		Aggregate:     aggregate,
+		Strict:        strict,
+		Flags:         flagKinds,
+		With:          with,
+		Prefix:        prefix,
+		Runtime:       runtime,
 	}
 	if err := GenEnv(cfg); err != nil {
 		log.Fatal(err)
@@ -62,10 +99,16 @@ func main() {
 type GenConfig struct {
 	PackageName   string
 	FileDir       string
-	ConfigType    string
+	ConfigTypes   []string
 	GoOutputFile  string
 	EnvOutputFile string
 	Verbose       bool
+	Aggregate     bool
+	Strict        bool
+	Flags         []string
+	With          []string
+	Prefix        string
+	Runtime       bool
 }
 
 func GenEnv(cfg GenConfig) error {
@@ -78,6 +121,27 @@ func GenEnv(cfg GenConfig) error {
 		}
 	}
 
+	if len(cfg.ConfigTypes) == 0 {
+		return fmt.Errorf("no config type given")
+	}
+
+	// a shared prefix would give every root the same keys
+	if cfg.Prefix != "" && len(cfg.ConfigTypes) > 1 {
+		return fmt.Errorf("--prefix can only be used with a single config type, use genenv:prefix directives instead")
+	}
+
+	for _, kind := range cfg.Flags {
+		if _, found := flagKinds[kind]; !found {
+			return fmt.Errorf("unknown flags kind '%v', expected pflag or std", kind)
+		}
+	}
+
+	for _, output := range cfg.With {
+		if !slices.Contains(withOutputs, output) {
+			return fmt.Errorf("unknown output '%v', expected any of: %v", output, strings.Join(withOutputs, ", "))
+		}
+	}
+
 	fset, pkgTypes, err := loadDocPackage(cfg.FileDir, cfg.PackageName)
 	if err != nil {
 		return err
@@ -85,15 +149,17 @@ func GenEnv(cfg GenConfig) error {
 
 	imports := &ImportCache{}
 	errs := &ErrorCache{}
-	parsers := &ParserCache{}
 	queue := &QueueCache{}
+	templates := &TemplateCache{}
 
-	// initial states
-	imports.Add("os", "os")
-
-	queue.Add(cfg.ConfigType)
+	// initial states, nested types shared by several roots are only
+	// queued and written once
+	for _, configType := range cfg.ConfigTypes {
+		queue.Add(configType)
+	}
 
 	var w bytes.Buffer
+	builders := make(map[string]*StructBuilder)
 
 	for !queue.IsEmpty() {
 		firstType := queue.Pop()
@@ -107,30 +173,97 @@ func GenEnv(cfg GenConfig) error {
 			tpe,
 			pkgTypes,
 			pkgTypes.Imports,
-			cfg.ConfigType,
+			cfg,
 			queue,
-			parsers,
 			errs,
 			imports,
+			templates,
 		)
 		if err != nil {
 			return err
 		}
-		b.Write(&w)
+
+		if err := b.Write(&w); err != nil {
+			return err
+		}
+
+		builders[firstType] = b
 	}
 
-	// write parsers to W so it can add imports and errors
-	if err := parsers.Write(&w); err != nil {
+	if err := checkNestedRoots(builders, cfg.ConfigTypes); err != nil {
 		return err
 	}
 
+	for _, configType := range cfg.ConfigTypes {
+		if err := checkDefaultRefs(builders, configType, builders[configType].keyPrefix); err != nil {
+			return err
+		}
+
+		if err := checkDefaultValues(builders, configType, builders[configType].keyPrefix); err != nil {
+			return err
+		}
+
+		if err := writeSchema(&w, builders, configType, templates); err != nil {
+			return err
+		}
+	}
+
+	// templates last as fields may add to them
+	if err := templates.Write(&w, imports, errs); err != nil {
+		return err
+	}
+
+	outputFile := cfg.GoOutputFile
+	if outputFile == "" {
+		tokFile := fset.File(pkgTypes.DocTypes[cfg.ConfigTypes[0]].Decl.TokPos)
+		nameNoExt := strings.TrimSuffix(tokFile.Name(), ".go")
+		outputFile = nameNoExt + "_gen.go"
+	}
+
+	// configs generated into other files of our package already declare
+	// the helpers and nested types they share with us
+	options := genOptions(cfg)
+	drop, err := pkgTypes.generatedOutside(outputFile, options)
+	if err != nil {
+		return err
+	}
+
+	// with a runtime every template and error comes from envrt instead
+	var qualify map[string]string
+	if cfg.Runtime {
+		qualify = templates.Helpers()
+		for name := range qualify {
+			drop[name] = true
+		}
+
+		imports.Add("envrt", runtimeImport)
+	}
+
 	// now write the file in order:
-	// package -> imports -> errors -> configs + parsers -> newline
+	// package -> imports -> errors -> configs + templates -> newline
+	var header, body bytes.Buffer
+	writeF(&header, "package %v\n\n", cfg.PackageName)
+	imports.Write(&header)
+	errs.Write(&body)
+	body.Write(w.Bytes())
+
+	rewritten, used, err := rewriteBody(header.Bytes(), body.Bytes(), drop, qualify)
+	if err != nil {
+		// Useful for debugging
+		os.Stderr.Write(header.Bytes())
+		os.Stderr.Write(body.Bytes())
+		return fmt.Errorf("error rewriting declarations: %w", err)
+	}
+
 	var topWriter bytes.Buffer
-	writeF(&topWriter, "package %v\n\n", cfg.PackageName)
+	imports.Keep(used)
+	writeF(&topWriter, "%v\n", generatedHeader)
+	if options != "" {
+		writeF(&topWriter, "%v %v\n", optionsHeader, options)
+	}
+	writeF(&topWriter, "\npackage %v\n\n", cfg.PackageName)
 	imports.Write(&topWriter)
-	errs.Write(&topWriter)
-	topWriter.Write(w.Bytes())
+	topWriter.Write(rewritten)
 	writeF(&topWriter, "\n")
 
 	formattedBytes, err := format.Source(topWriter.Bytes())
@@ -140,11 +273,8 @@ func GenEnv(cfg GenConfig) error {
 		return fmt.Errorf("error formatting: %w", err)
 	}
 
-	outputFile := cfg.GoOutputFile
-	if outputFile == "" {
-		tokFile := fset.File(pkgTypes.DocTypes[cfg.ConfigType].Decl.TokPos)
-		nameNoExt := strings.TrimSuffix(tokFile.Name(), ".go")
-		outputFile = nameNoExt + "_gen.go"
+	if err := pkgTypes.checkClashes(formattedBytes, outputFile); err != nil {
+		return err
 	}
 
 	f, err := os.Create(outputFile)
@@ -153,12 +283,30 @@ func GenEnv(cfg GenConfig) error {
 	}
 
 	f.Write(formattedBytes)
+
+	if cfg.EnvOutputFile != "" {
+		example := &EnvExample{builders: builders}
+
+		var envWriter bytes.Buffer
+		if err := example.Write(&envWriter, cfg.ConfigTypes); err != nil {
+			return err
+		}
+
+		if err := os.WriteFile(cfg.EnvOutputFile, envWriter.Bytes(), 0o644); err != nil {
+			return err
+		}
+	}
+
 	return nil
 }
 
 func loadDocPackage(dirName, pkgName string) (*token.FileSet, *PackageTypes, error) {
+	if dirName == "" {
+		dirName = "."
+	}
+
 	fset := token.NewFileSet()
-	pkgs, err := parser.ParseDir(fset, "./"+dirName, func(fi fs.FileInfo) bool {
+	pkgs, err := parser.ParseDir(fset, dirName, func(fi fs.FileInfo) bool {
 		logLine("file found:", fi.Name())
 		return true
 	}, parser.ParseComments)
@@ -175,13 +323,22 @@ func loadDocPackage(dirName, pkgName string) (*token.FileSet, *PackageTypes, err
 		return fset, nil, fmt.Errorf("package '%v' not found", pkgName)
 	}
 
-	docPkg := doc.New(pkg, "./", 0)
+	// keep unexported fields so we can report them as skipped, and the
+	// type comments so directives can be read
+	docPkg := doc.New(pkg, "./", doc.AllDecls|doc.PreserveAST)
 	pkgTypes := &PackageTypes{
 		Imports:  make(map[string]string),
 		DocTypes: make(map[string]*doc.Type),
+		Declared: make(map[string]Decl),
+		Options:  make(map[string]string),
 	}
 
-	for _, files := range pkg.Files {
+	for fileName, files := range pkg.Files {
+		addDeclared(pkgTypes.Declared, fset, files)
+		if options, found := headerOptions(files); found {
+			pkgTypes.Options[fileName] = options
+		}
+
 		for _, fileImp := range files.Imports {
 			importPath := strings.Trim(fileImp.Path.Value, "\"")
 			split := strings.Split(importPath, "/")
@@ -199,6 +356,53 @@ func loadDocPackage(dirName, pkgName string) (*token.FileSet, *PackageTypes, err
 	return fset, pkgTypes, nil
 }
 
+// genOptions lists the options changing what we declare for nested types
+// and templates as flags, in the same order on every run.
+func genOptions(cfg GenConfig) string {
+	var options []string
+	if cfg.Aggregate {
+		options = append(options, "--aggregate")
+	}
+
+	if cfg.Strict {
+		options = append(options, "--strict")
+	}
+
+	if len(cfg.Flags) > 0 {
+		options = append(options, "--flags="+sortedList(cfg.Flags))
+	}
+
+	if len(cfg.With) > 0 {
+		options = append(options, "--with="+sortedList(cfg.With))
+	}
+
+	if cfg.Runtime {
+		options = append(options, "--runtime")
+	}
+
+	return strings.Join(options, " ")
+}
+
+func sortedList(values []string) string {
+	values = slices.Clone(values)
+	slices.Sort(values)
+	return strings.Join(slices.Compact(values), ",")
+}
+
+// checkNestedRoots fails if a root type is also nested in another config,
+// as roots are loaded without a prefix.
+func checkNestedRoots(builders map[string]*StructBuilder, rootTypes []string) error {
+	for _, b := range builders {
+		for _, f := range b.order {
+			if f.customType && slices.Contains(rootTypes, f.typeName) {
+				return fmt.Errorf("config type '%v' is also nested in '%v'", f.typeName, b.name)
+			}
+		}
+	}
+
+	return nil
+}
+
 func writeF(w io.Writer, format string, args ...any) error {
 	_, err := w.Write([]byte(fmt.Sprintf(format, args...)))
 	return err
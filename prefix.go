@@ -0,0 +1,50 @@
+package main
+
+import (
+	"go/ast"
+	"go/doc"
+	"io"
+	"strings"
+)
+
+const prefixDirectiveName = "//genenv:prefix "
+
+// prefixDirective returns the key prefix from a "//genenv:prefix MYAPP"
+// directive in the docs of a type, or an empty string without one.
+func prefixDirective(tpe *doc.Type) string {
+	groups := []*ast.CommentGroup{tpe.Decl.Doc}
+	for _, spec := range tpe.Decl.Specs {
+		if typeSpec, ok := spec.(*ast.TypeSpec); ok && typeSpec.Name.Name == tpe.Name {
+			groups = append(groups, typeSpec.Doc)
+		}
+	}
+
+	for _, group := range groups {
+		if group == nil {
+			continue
+		}
+
+		for _, comment := range group.List {
+			if strings.HasPrefix(comment.Text, prefixDirectiveName) {
+				return strings.TrimSpace(strings.TrimPrefix(comment.Text, prefixDirectiveName))
+			}
+		}
+	}
+
+	return ""
+}
+
+// writePrefix writes the prefix of our root type as a const so callers
+// can build keys or list values under it.
+func (b *StructBuilder) writePrefix(w io.Writer) {
+	if !b.rootType || b.keyPrefix == "" {
+		return
+	}
+
+	writeF(
+		w,
+		"// %[1]vPrefix is prepended to every key of %[1]v.\nconst %[1]vPrefix = %[2]q\n\n",
+		b.name,
+		b.keyPrefix,
+	)
+}
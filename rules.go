@@ -0,0 +1,262 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"reflect"
+	"strings"
+)
+
+// Rule is a cross field check run once every field of a struct is loaded.
+type Rule struct {
+	// Cond is a go expression that is true when the rule is broken
+	Cond string
+	// ErrExpr is a go expression of the field error returned when it is
+	ErrExpr string
+}
+
+var compareOps = map[string]string{
+	"gt":  ">",
+	"gte": ">=",
+	"lt":  "<",
+	"lte": "<=",
+}
+
+// loadRuleTags reads the raw cross field tags of a field, they are
+// resolved by the struct builder once all fields are known.
+func (f *Field) loadRuleTags(tags reflect.StructTag) {
+	f.requiredIf, _ = tags.Lookup("required_if")
+	f.requiredWith, _ = tags.Lookup("required_with")
+	f.exclusive, _ = tags.Lookup("exclusive")
+
+	for _, name := range []string{"gt", "gte", "lt", "lte"} {
+		if other, ok := tags.Lookup(name); ok {
+			f.compares = append(f.compares, [2]string{name, other})
+		}
+	}
+}
+
+// setExpr returns a go expression that is true when the field is not
+// its zero value.
+func (f *Field) setExpr() (string, error) {
+	return f.setExprOf("c")
+}
+
+// setExprOf is setExpr for the field of recv instead of c.
+func (f *Field) setExprOf(recv string) (string, error) {
+	value := recv + "." + f.goPath
+	switch {
+	case f.pointer:
+		return value + " != nil", nil
+	case f.slice:
+		return "len(" + value + ") > 0", nil
+	case f.customType:
+		return "", fmt.Errorf("struct field: '%v' can not be used in a rule", f.varName)
+	case f.typeName == "string":
+		return value + ` != ""`, nil
+	case f.typeName == "bool":
+		return value, nil
+	default:
+		return value + " != 0", nil
+	}
+}
+
+// equalExpr returns a go expression comparing the field to value, parsed
+// the same way the field is loaded.
+func (f *Field) equalExpr(value string) (string, error) {
+	info, found := convMap[f.typeName]
+	if f.pointer || f.slice || f.customType || !found {
+		return "", fmt.Errorf("field: '%v' can not be compared to a value", f.varName)
+	}
+
+	literal, err := info.Literal(value)
+	if err != nil {
+		return "", fmt.Errorf("invalid value %q for field: '%v' (%v): %w", value, f.varName, f.typeName, err)
+	}
+
+	return fmt.Sprintf("c.%v == %v", f.goPath, literal), nil
+}
+
+// resolveRules turns the cross field tags of every field into rules.
+func (b *StructBuilder) resolveRules() error {
+	lookup := func(f *Field, tag, name string) (*Field, error) {
+		other, found := b.fields[name]
+		if !found {
+			return nil, fmt.Errorf("%v tag of field: '%v' references unknown field: '%v'", tag, f.varName, name)
+		}
+
+		return other, nil
+	}
+
+	groups := make(map[string][]*Field)
+	groupRequired := make(map[string]bool)
+	var groupNames []string
+
+	for _, f := range b.order {
+		if f.requiredWith != "" || f.requiredIf != "" {
+			isSet, err := f.setExpr()
+			if err != nil {
+				return err
+			}
+
+			if f.requiredWith != "" {
+				other, err := lookup(f, "required_with", f.requiredWith)
+				if err != nil {
+					return err
+				}
+
+				otherSet, err := other.setExpr()
+				if err != nil {
+					return err
+				}
+
+				b.rules = append(b.rules, Rule{
+					Cond:    fmt.Sprintf("%v && !(%v)", otherSet, isSet),
+					ErrExpr: f.ruleErrorExpr(`"required_with=" + ` + other.keyExpr()),
+				})
+			}
+
+			if f.requiredIf != "" {
+				name, value, found := strings.Cut(f.requiredIf, "=")
+				if !found {
+					return fmt.Errorf("required_if tag of field: '%v' must be written as Field=value", f.varName)
+				}
+
+				other, err := lookup(f, "required_if", name)
+				if err != nil {
+					return err
+				}
+
+				otherEqual, err := other.equalExpr(value)
+				if err != nil {
+					return fmt.Errorf("required_if tag of field: '%v': %w", f.varName, err)
+				}
+
+				b.rules = append(b.rules, Rule{
+					Cond:    fmt.Sprintf("%v && !(%v)", otherEqual, isSet),
+					ErrExpr: f.ruleErrorExpr(`"required_if=" + ` + other.keyExpr() + fmt.Sprintf(" + %q", "="+value)),
+				})
+			}
+		}
+
+		for _, cmp := range f.compares {
+			other, err := lookup(f, cmp[0], cmp[1])
+			if err != nil {
+				return err
+			}
+
+			if f.typeName != other.typeName || f.slice || f.pointer || other.slice || other.pointer {
+				return fmt.Errorf("%v tag of field: '%v' must reference a field of the same type", cmp[0], f.varName)
+			}
+
+			if !convMap[f.typeName].Ordered {
+				return fmt.Errorf("%v tag of field: '%v' can not compare unordered type: %v", cmp[0], f.varName, f.typeName)
+			}
+
+			b.rules = append(b.rules, Rule{
+				Cond:    fmt.Sprintf("!(c.%v %v c.%v)", f.goPath, compareOps[cmp[0]], other.goPath),
+				ErrExpr: f.ruleErrorExpr(fmt.Sprintf("%q + ", cmp[0]+"=") + other.keyExpr()),
+			})
+		}
+
+		if f.exclusive != "" {
+			name, opts := parseEnvTag(f.exclusive)
+			if _, found := groups[name]; !found {
+				groupNames = append(groupNames, name)
+				groupRequired[name] = opts["required"]
+			}
+
+			// every field of a group has to agree, otherwise a typo would
+			// silently split the group in two
+			if groupRequired[name] != opts["required"] {
+				return fmt.Errorf("exclusive group '%v' of field: '%v' must be required on every field or none", name, f.varName)
+			}
+
+			groups[name] = append(groups[name], f)
+		}
+	}
+
+	for _, name := range groupNames {
+		group := groups[name]
+		sets := make([]string, 0, len(group))
+		keys := make([]string, 0, len(group))
+		goFields := make([]string, 0, len(group))
+		for _, f := range group {
+			isSet, err := f.setExpr()
+			if err != nil {
+				return err
+			}
+
+			sets = append(sets, isSet)
+			keys = append(keys, f.keyExpr())
+			goFields = append(goFields, b.name+"."+f.goPath)
+		}
+
+		b.templates.Use("CountTrue", "FieldError")
+		b.importCache.Add("strings", "strings")
+
+		cond := fmt.Sprintf("countTrue(%v) > 1", strings.Join(sets, ", "))
+		constraint := "at most one set"
+		if groupRequired[name] {
+			cond = fmt.Sprintf("countTrue(%v) != 1", strings.Join(sets, ", "))
+			constraint = "exactly one set"
+		}
+
+		// the group has no single field, so every field is named instead
+		keysExpr := fmt.Sprintf("strings.Join([]string{%v}, \", \")", strings.Join(keys, ", "))
+		b.rules = append(b.rules, Rule{
+			Cond: cond,
+			ErrExpr: fmt.Sprintf(
+				"&FieldError{Key: %v, GoField: %q, Err: &ValidationError{Key: %[1]v, Constraint: %[3]q}}",
+				keysExpr,
+				strings.Join(goFields, ", "),
+				constraint,
+			),
+		})
+	}
+
+	return nil
+}
+
+// ruleErrorExpr returns the go expression of the field error returned
+// when we break a rule with constraintExpr.
+func (f *Field) ruleErrorExpr(constraintExpr string) string {
+	return f.describedErrorExpr(f.keyExpr(), fmt.Sprintf(
+		"&ValidationError{Key: %v, Constraint: %v}",
+		f.keyExpr(),
+		constraintExpr,
+	))
+}
+
+func (b *StructBuilder) writeRules(w io.Writer) {
+	if len(b.rules) == 0 {
+		return
+	}
+
+	b.templates.Use("ValidationError")
+
+	// fields that failed are left at their zero value, comparing them
+	// would only report more errors caused by the same failure
+	if b.aggregate {
+		writeF(w, "if len(errs) == 0 {\n")
+	}
+
+	for i, rule := range b.rules {
+		if i > 0 {
+			writeF(w, "\n")
+		}
+
+		writeF(
+			w,
+			"if %v {\n%v\n}\n",
+			rule.Cond,
+			failStmt(b.aggregate, rule.ErrExpr),
+		)
+	}
+
+	if b.aggregate {
+		writeF(w, "}\n")
+	}
+
+	writeF(w, "\n")
+}
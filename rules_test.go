@@ -0,0 +1,158 @@
+package main
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestResolveRules(t *testing.T) {
+	for _, tc := range []struct {
+		name    string
+		fields  string
+		wantErr string
+	}{
+		{
+			name:   "required_if string",
+			fields: "Mode string\nToken string `default:\"\" required_if:\"Mode=remote\"`",
+		},
+		{
+			name:   "required_if bool",
+			fields: "Tls bool `default:\"false\"`\nCert string `default:\"\" required_if:\"Tls=yes\"`",
+		},
+		{
+			name:   "required_if duration",
+			fields: "Wait time.Duration `default:\"0s\"`\nReason string `default:\"\" required_if:\"Wait=1m\"`",
+		},
+		{
+			name:    "required_if bad int",
+			fields:  "Port int `default:\"0\"`\nHost string `default:\"\" required_if:\"Port=abc\"`",
+			wantErr: `required_if tag of field: 'Host': invalid value "abc" for field: 'Port' (int)`,
+		},
+		{
+			name:    "required_if bad bool",
+			fields:  "Tls bool `default:\"false\"`\nCert string `default:\"\" required_if:\"Tls=maybe\"`",
+			wantErr: `invalid value "maybe" for field: 'Tls' (bool)`,
+		},
+		{
+			name:    "required_if without value",
+			fields:  "Mode string\nToken string `default:\"\" required_if:\"Mode\"`",
+			wantErr: "required_if tag of field: 'Token' must be written as Field=value",
+		},
+		{
+			name:    "required_if unknown field",
+			fields:  "Token string `default:\"\" required_if:\"Mode=remote\"`",
+			wantErr: "references unknown field: 'Mode'",
+		},
+		{
+			name:   "required_with",
+			fields: "Cert string `default:\"\"`\nKey string `default:\"\" required_with:\"Cert\"`",
+		},
+		{
+			name:    "required_with unknown field",
+			fields:  "Key string `default:\"\" required_with:\"Cert\"`",
+			wantErr: "references unknown field: 'Cert'",
+		},
+		{
+			name:   "gt int",
+			fields: "Min int\nMax int `gt:\"Min\"`",
+		},
+		{
+			name:   "lte duration",
+			fields: "Read time.Duration\nIdle time.Duration `lte:\"Read\"`",
+		},
+		{
+			name:    "gt bool",
+			fields:  "A bool\nB bool `gt:\"A\"`",
+			wantErr: "gt tag of field: 'B' can not compare unordered type: bool",
+		},
+		{
+			name:    "lt other type",
+			fields:  "Min int\nMax string `lt:\"Min\"`",
+			wantErr: "lt tag of field: 'Max' must reference a field of the same type",
+		},
+		{
+			name:   "exclusive",
+			fields: "File string `default:\"\" exclusive:\"source\"`\nUrl string `default:\"\" exclusive:\"source\"`",
+		},
+		{
+			name:   "exclusive required",
+			fields: "File string `default:\"\" exclusive:\"source,required\"`\nUrl string `default:\"\" exclusive:\"source,required\"`",
+		},
+		{
+			name:    "exclusive mixed",
+			fields:  "File string `default:\"\" exclusive:\"source\"`\nUrl string `default:\"\" exclusive:\"source,required\"`",
+			wantErr: "exclusive group 'source' of field: 'Url' must be required on every field or none",
+		},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			src := "package main\n\nimport \"time\"\n\ntype Config struct {\n" + tc.fields + "\n}\n"
+			_, err := generate(t, src, GenConfig{})
+			if tc.wantErr == "" {
+				if err != nil {
+					t.Fatal(err)
+				}
+
+				return
+			}
+
+			if err == nil || !strings.Contains(err.Error(), tc.wantErr) {
+				t.Fatalf("expected %q, got %v", tc.wantErr, err)
+			}
+		})
+	}
+}
+
+func TestGeneratedRules(t *testing.T) {
+	got := runGenerated(t, `package main
+
+import "time"
+
+type Config struct {
+	Tls    bool          `+"`default:\"false\"`"+`
+	Cert   string        `+"`default:\"\" required_if:\"Tls=yes\"`"+`
+	Key    string        `+"`default:\"\" required_with:\"Cert\"`"+`
+	Wait   time.Duration `+"`default:\"0s\"`"+`
+	Reason string        `+"`default:\"\" required_if:\"Wait=1m\"`"+`
+	Min    int           `+"`default:\"0\"`"+`
+	Max    int           `+"`default:\"10\" gt:\"Min\"`"+`
+	File   string        `+"`default:\"\" exclusive:\"source\"`"+`
+	Url    string        `+"`default:\"\" exclusive:\"source\"`"+`
+}
+`, GenConfig{}, `package main
+
+import (
+	"fmt"
+)
+
+func main() {
+	for _, env := range []MapLookup{
+		{},
+		{"TLS": "true"},
+		{"TLS": "true", "CERT": "c"},
+		{"TLS": "true", "CERT": "c", "KEY": "k"},
+		{"WAIT": "60s"},
+		{"WAIT": "60s", "REASON": "slow"},
+		{"MIN": "10"},
+		{"FILE": "f", "URL": "u"},
+	} {
+		_, err := NewConfigFrom(env)
+		fmt.Println(err)
+	}
+}
+`)
+
+	// required_if values are parsed as the type of the field they compare
+	want := strings.Join([]string{
+		"<nil>",
+		"CERT: validation failed: CERT does not satisfy required_if=TLS=yes (Config.Cert string)",
+		"KEY: validation failed: KEY does not satisfy required_with=CERT (Config.Key string)",
+		"<nil>",
+		"REASON: validation failed: REASON does not satisfy required_if=WAIT=1m (Config.Reason string)",
+		"<nil>",
+		"MAX: validation failed: MAX does not satisfy gt=MIN (Config.Max int)",
+		"FILE, URL: validation failed: FILE, URL does not satisfy at most one set (Config.File, Config.Url)",
+	}, "\n")
+	if got != want {
+		t.Fatalf("got:\n%v\nwant:\n%v", got, want)
+	}
+}
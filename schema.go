@@ -0,0 +1,138 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"strings"
+)
+
+// writeSchema writes the schema of a root type listing every key it loads
+// in declaration order, nested types are listed with their full prefix.
+func writeSchema(w io.Writer, builders map[string]*StructBuilder, rootType string, templates *TemplateCache) error {
+	templates.Use("KeySchema")
+
+	b := builders[rootType]
+	writeF(
+		w,
+		"// %[1]vSchema lists every key loaded by %[1]v in declaration order.\nvar %[1]vSchema = []KeySchema{\n",
+		rootType,
+	)
+
+	if err := writeStructSchema(w, builders, b, b.keyPrefix, "", ""); err != nil {
+		return err
+	}
+
+	writeF(w, "}\n\n")
+	return nil
+}
+
+func writeStructSchema(w io.Writer, builders map[string]*StructBuilder, b *StructBuilder, prefix, goPrefix, loadIf string) error {
+	typeField, hasTypeField := b.fields["Type"]
+
+	for _, f := range b.order {
+		key := joinKey(prefix, f.envKey)
+		fieldLoadIf := loadIf
+		if hasTypeField && f != typeField {
+			fieldLoadIf = fmt.Sprintf("%v=%v", joinKey(prefix, typeField.envKey), f.envKey)
+		}
+
+		if f.customType {
+			nested, found := builders[f.typeName]
+			if !found {
+				return fmt.Errorf("nested type '%v' was not built", f.typeName)
+			}
+
+			if err := writeStructSchema(w, builders, nested, key, goPrefix+f.goPath+".", fieldLoadIf); err != nil {
+				return err
+			}
+
+			continue
+		}
+
+		typeName := f.typeName
+		if f.slice {
+			typeName = "[]" + typeName
+		}
+
+		writeF(w, "{\nKey: %q,\nGoPath: %q,\nType: %q,\n", key, goPrefix+f.goPath, typeName)
+
+		// secret defaults are left out so they are not copied around
+		if f.hasDefault && !f.secret {
+			writeF(w, "Default: %q,\n", f.defaultValue)
+		}
+
+		if f.hasDefault {
+			writeF(w, "HasDefault: true,\n")
+		}
+
+		if f.required {
+			writeF(w, "Required: true,\n")
+		}
+
+		if f.secret {
+			writeF(w, "Secret: true,\n")
+		}
+
+		if hasTypeField && f == typeField {
+			writeF(w, "Allowed: %v,\n", stringsLiteral(buildTypeValues(b)))
+		}
+
+		if len(f.checks) > 0 {
+			constraints := make([]string, len(f.checks))
+			for i, check := range f.checks {
+				constraints[i] = check.Constraint
+			}
+
+			writeF(w, "Constraints: %v,\n", stringsLiteral(constraints))
+		}
+
+		if len(f.aliases) > 0 {
+			writeF(w, "Aliases: %v,\n", stringsLiteral(prefixAll(prefix, f.aliases)))
+		}
+
+		if fieldLoadIf != "" {
+			writeF(w, "LoadIf: %q,\n", fieldLoadIf)
+		}
+
+		if f.deprecated {
+			writeF(w, "Deprecated: true,\n")
+		}
+
+		if doc := strings.TrimSpace(f.docs); doc != "" {
+			writeF(w, "Doc: %q,\n", doc)
+		}
+
+		writeF(w, "},\n")
+	}
+
+	return nil
+}
+
+func stringsLiteral(values []string) string {
+	quoted := make([]string, len(values))
+	for i, v := range values {
+		quoted[i] = fmt.Sprintf("%q", v)
+	}
+
+	return "[]string{" + strings.Join(quoted, ", ") + "}"
+}
+
+func buildTypeValues(b *StructBuilder) []string {
+	var values []string
+	for _, f := range b.order {
+		if f.varName != "Type" {
+			values = append(values, f.envKey)
+		}
+	}
+
+	return values
+}
+
+func prefixAll(prefix string, keys []string) []string {
+	prefixed := make([]string, len(keys))
+	for i, key := range keys {
+		prefixed[i] = joinKey(prefix, key)
+	}
+
+	return prefixed
+}
@@ -0,0 +1,90 @@
+package main
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestGeneratedSchema(t *testing.T) {
+	generated := mustGenerate(t, `package main
+
+type Config struct {
+	// Port to listen on.
+	Port int `+"`default:\"80\" min:\"1\" aliases:\"OLD_PORT\"`"+`
+	// Token authenticates clients.
+	Token string `+"`secret:\"true\" default:\"abc\"`"+`
+	// Deprecated: use Port.
+	Legacy []string
+	Store  StoreConfig
+}
+
+type StoreConfig struct {
+	Type string     `+"`buildType:\"Store\"`"+`
+	Mem  *MemConfig `+"`env:\"MEM\"`"+`
+}
+
+type MemConfig struct {
+	Size int `+"`default:\"10\"`"+`
+}
+
+type Store interface{}
+
+func (c *MemConfig) NewMem() (Store, error) {
+	return nil, nil
+}
+`, GenConfig{})
+
+	_, schema, found := strings.Cut(generated, "var ConfigSchema = []KeySchema{\n")
+	if !found {
+		t.Fatalf("expected ConfigSchema in:\n%v", generated)
+	}
+
+	schema, _, _ = strings.Cut(schema, "\n}\n")
+
+	// nested keys are listed with their full prefix and go path
+	want := `	{
+		Key:         "PORT",
+		GoPath:      "Port",
+		Type:        "int",
+		Default:     "80",
+		HasDefault:  true,
+		Constraints: []string{"min=1"},
+		Aliases:     []string{"OLD_PORT"},
+		Doc:         "Port to listen on.",
+	},
+	{
+		Key:        "TOKEN",
+		GoPath:     "Token",
+		Type:       "string",
+		HasDefault: true,
+		Secret:     true,
+		Doc:        "Token authenticates clients.",
+	},
+	{
+		Key:        "LEGACY",
+		GoPath:     "Legacy",
+		Type:       "[]string",
+		Required:   true,
+		Deprecated: true,
+		Doc:        "Deprecated: use Port.",
+	},
+	{
+		Key:      "STORE_TYPE",
+		GoPath:   "Store.Type",
+		Type:     "string",
+		Required: true,
+		Allowed:  []string{"MEM"},
+	},
+	{
+		Key:        "STORE_MEM_SIZE",
+		GoPath:     "Store.Mem.Size",
+		Type:       "int",
+		Default:    "10",
+		HasDefault: true,
+		LoadIf:     "STORE_TYPE=MEM",
+	},`
+
+	if schema != want {
+		t.Fatalf("got:\n%v\nwant:\n%v", schema, want)
+	}
+}
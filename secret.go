@@ -0,0 +1,178 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"strings"
+)
+
+// secretWords are the last words of field names treated as secret
+// without a secret tag.
+var secretWords = map[string]bool{
+	"Password": true,
+	"Passwd":   true,
+	"Secret":   true,
+	"Token":    true,
+	"Key":      true,
+}
+
+// isSecretName checks if the last word of a camel case name is one that
+// usually holds a credential, such as DBPassword or APIKey.
+func isSecretName(name string) bool {
+	for i := len(name) - 1; i >= 0; i-- {
+		if name[i] >= 'A' && name[i] <= 'Z' {
+			return secretWords[name[i:]]
+		}
+	}
+
+	return false
+}
+
+// fmtNode is a field or inline struct of a config in declaration order,
+// used to format configs the way fmt would with secrets redacted.
+type fmtNode struct {
+	name     string
+	typeName string
+	field    *Field
+	children []*fmtNode
+}
+
+// fmtTree groups our fields by the inline structs they were promoted
+// from so they print nested like go does.
+func (b *StructBuilder) fmtTree() *fmtNode {
+	root := &fmtNode{typeName: b.name}
+	for _, f := range b.order {
+		node := root
+		parts := strings.Split(f.goPath, ".")
+		for i, part := range parts[:len(parts)-1] {
+			last := len(node.children) - 1
+			if last >= 0 && node.children[last].field == nil && node.children[last].name == part {
+				node = node.children[last]
+				continue
+			}
+
+			group := &fmtNode{
+				name:     part,
+				typeName: b.inlineTypes[strings.Join(parts[:i+1], ".")],
+			}
+			node.children = append(node.children, group)
+			node = group
+		}
+
+		node.children = append(node.children, &fmtNode{name: parts[len(parts)-1], field: f})
+	}
+
+	return root
+}
+
+// valueExpr returns the go expression printed for a field, secrets are
+// replaced by a redacted string.
+func (f *Field) valueExpr() string {
+	if !f.secret {
+		return "c." + f.goPath
+	}
+
+	isSet, _ := f.setExpr()
+	return fmt.Sprintf("redact(%v)", isSet)
+}
+
+// formatArgs returns the format string and args printing node like %+v,
+// or like %#v if goSyntax is set.
+func (n *fmtNode) formatArgs(pkgName string, goSyntax bool) (string, []string) {
+	var (
+		parts []string
+		args  []string
+	)
+
+	for _, child := range n.children {
+		if child.field == nil {
+			format, childArgs := child.formatArgs(pkgName, goSyntax)
+			parts = append(parts, child.name+":"+format)
+			args = append(args, childArgs...)
+			continue
+		}
+
+		verb := "%v"
+		if goSyntax {
+			verb = "%#v"
+		}
+
+		parts = append(parts, child.name+":"+verb)
+		args = append(args, child.field.valueExpr())
+	}
+
+	if goSyntax {
+		return pkgName + "." + n.typeName + "{" + strings.Join(parts, ", ") + "}", args
+	}
+
+	return "{" + strings.Join(parts, " ") + "}", args
+}
+
+// logAttrs returns the slog attrs of each field of node.
+func (n *fmtNode) logAttrs() []string {
+	attrs := make([]string, 0, len(n.children))
+	for _, child := range n.children {
+		switch {
+		case child.field == nil:
+			attrs = append(attrs, fmt.Sprintf("slog.Group(%q, %v)", child.name, strings.Join(child.logAttrs(), ", ")))
+		case child.field.customType && child.field.pointer:
+			attrs = append(attrs, fmt.Sprintf("logPtr(%q, c.%v)", child.name, child.field.goPath))
+		default:
+			attrs = append(attrs, fmt.Sprintf("slog.Any(%q, %v)", child.name, child.field.valueExpr()))
+		}
+	}
+
+	return attrs
+}
+
+// writeRedacted writes the String, GoString and LogValue methods of our
+// type, each printing secret fields redacted.
+func (b *StructBuilder) writeRedacted(w io.Writer) {
+	b.templates.Use("Redact", "LogPtr")
+	b.importCache.Add("fmt", "fmt")
+	b.importCache.Add("slog", "log/slog")
+
+	tree := b.fmtTree()
+
+	format, args := tree.formatArgs(b.pkgName, false)
+	writeF(
+		w,
+		"// String formats %[1]v like %%+v with secret fields redacted.\nfunc (c %[1]v) String() string {\nreturn fmt.Sprintf(%[2]q%[3]v)\n}\n\n",
+		b.name,
+		format,
+		joinArgs(args),
+	)
+
+	format, args = tree.formatArgs(b.pkgName, true)
+	writeF(
+		w,
+		"// GoString formats %[1]v like %%#v with secret fields redacted.\nfunc (c %[1]v) GoString() string {\nreturn fmt.Sprintf(%[2]q%[3]v)\n}\n\n",
+		b.name,
+		format,
+		joinArgs(args),
+	)
+
+	writeF(
+		w,
+		"// LogValue logs %[1]v as a group with secret fields redacted.\nfunc (c %[1]v) LogValue() slog.Value {\nreturn slog.GroupValue(\n%[2]v)\n}\n\n",
+		b.name,
+		joinLines(tree.logAttrs()),
+	)
+}
+
+func joinArgs(args []string) string {
+	if len(args) == 0 {
+		return ""
+	}
+
+	return ", " + strings.Join(args, ", ")
+}
+
+func joinLines(args []string) string {
+	var sb strings.Builder
+	for _, arg := range args {
+		sb.WriteString(arg + ",\n")
+	}
+
+	return sb.String()
+}
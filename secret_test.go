@@ -0,0 +1,61 @@
+package main
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestIsSecretName(t *testing.T) {
+	for _, tc := range []struct {
+		name string
+		want bool
+	}{
+		{name: "Password", want: true},
+		{name: "DBPassword", want: true},
+		{name: "APIKey", want: true},
+		{name: "AuthToken", want: true},
+		{name: "ClientSecret", want: true},
+		{name: "Passwd", want: true},
+		{name: "Keys"},
+		{name: "KeyFile"},
+		{name: "Host"},
+		{name: "token"},
+	} {
+		if got := isSecretName(tc.name); got != tc.want {
+			t.Errorf("isSecretName(%q) = %v, want %v", tc.name, got, tc.want)
+		}
+	}
+}
+
+func TestGeneratedRedacted(t *testing.T) {
+	generated := mustGenerate(t, `package main
+
+type Config struct {
+	Host     string `+"`default:\"localhost\"`"+`
+	Password string
+	Token    string `+"`secret:\"true\" default:\"abc\"`"+`
+	Public   string `+"`secret:\"false\"`"+`
+	Db       *DbConfig
+}
+
+type DbConfig struct {
+	Dsn string `+"`secret:\"true\"`"+`
+}
+`, GenConfig{})
+
+	hasAll(t, generated,
+		`return fmt.Sprintf("{Host:%v Password:%v Token:%v Public:%v Db:%v}", c.Host, redact(c.Password != ""), redact(c.Token != ""), c.Public, c.Db)`,
+		`return fmt.Sprintf("main.Config{Host:%#v, Password:%#v, Token:%#v, Public:%#v, Db:%#v}", c.Host, redact(c.Password != ""), redact(c.Token != ""), c.Public, c.Db)`,
+		`slog.Any("Password", redact(c.Password != "")),`,
+		`slog.Any("Public", c.Public),`,
+		`logPtr("Db", c.Db),`,
+		// nested types redact their own secrets
+		`return fmt.Sprintf("{Dsn:%v}", redact(c.Dsn != ""))`,
+		`slog.Any("Dsn", redact(c.Dsn != "")),`,
+	)
+
+	// secret defaults are only loaded, never listed in the schema
+	if n := strings.Count(generated, `"abc"`); n != 1 {
+		t.Errorf("expected the secret default once, got %v", n)
+	}
+}
@@ -0,0 +1,342 @@
+package main
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"go/ast"
+	"go/parser"
+	"go/printer"
+	"go/token"
+	"go/types"
+	"path/filepath"
+	"slices"
+	"strings"
+)
+
+// declName returns the name of a func, or "Type.Method" for methods, so
+// methods of different types do not collide.
+func declName(fn *ast.FuncDecl) string {
+	if fn.Recv == nil || len(fn.Recv.List) == 0 {
+		return fn.Name.Name
+	}
+
+	recv := fn.Recv.List[0].Type
+	if star, ok := recv.(*ast.StarExpr); ok {
+		recv = star.X
+	}
+
+	switch r := recv.(type) {
+	case *ast.Ident:
+		return r.Name + "." + fn.Name.Name
+	case *ast.IndexExpr:
+		return r.X.(*ast.Ident).Name + "." + fn.Name.Name
+	case *ast.IndexListExpr:
+		return r.X.(*ast.Ident).Name + "." + fn.Name.Name
+	}
+
+	return fn.Name.Name
+}
+
+// Decl is a top level declaration of our package.
+type Decl struct {
+	// Kind is "type", "func", "method", "var" or "const"
+	Kind string
+	Pos  token.Position
+	// Generated is set for declarations in files written by genenv
+	Generated bool
+}
+
+// addDeclared adds the top level names of file to declared.
+func addDeclared(declared map[string]Decl, fset *token.FileSet, file *ast.File) {
+	generated := writtenByUs(file)
+	add := func(name, kind string, pos token.Pos) {
+		declared[name] = Decl{Kind: kind, Pos: fset.Position(pos), Generated: generated}
+	}
+
+	for _, decl := range file.Decls {
+		switch d := decl.(type) {
+		case *ast.FuncDecl:
+			kind := "func"
+			if d.Recv != nil {
+				kind = "method"
+			}
+
+			add(declName(d), kind, d.Name.Pos())
+		case *ast.GenDecl:
+			for _, spec := range d.Specs {
+				switch s := spec.(type) {
+				case *ast.TypeSpec:
+					add(s.Name.Name, "type", s.Name.Pos())
+				case *ast.ValueSpec:
+					for _, name := range s.Names {
+						add(name.Name, d.Tok.String(), name.Pos())
+					}
+				}
+			}
+		}
+	}
+}
+
+// writtenByUs checks if file starts with our generated header. Files of
+// other generators such as stringer are treated as written by hand, so
+// clashing with them is reported instead of dropping our declarations.
+func writtenByUs(file *ast.File) bool {
+	_, found := headerOptions(file)
+	return found
+}
+
+// headerOptions returns the options file was generated with, if it starts
+// with our generated header.
+func headerOptions(file *ast.File) (string, bool) {
+	var (
+		options string
+		found   bool
+	)
+
+	for _, group := range file.Comments {
+		if group.Pos() >= file.Package {
+			break
+		}
+
+		for _, c := range group.List {
+			if c.Text == generatedHeader {
+				found = true
+			}
+
+			if opts, ok := strings.CutPrefix(c.Text, optionsHeader); ok {
+				options = strings.TrimSpace(opts)
+			}
+		}
+	}
+
+	return options, found
+}
+
+// generatedOutside returns the top level names genenv wrote into other
+// files of our package, such as the helpers and nested types of a config
+// generated by a second run. Files generated with other options declare
+// them differently, so they can not be shared.
+func (p *PackageTypes) generatedOutside(outputFile, options string) (map[string]bool, error) {
+	files := make([]string, 0, len(p.Options))
+	for file := range p.Options {
+		files = append(files, file)
+	}
+
+	slices.Sort(files)
+	for _, file := range files {
+		if theirs := p.Options[file]; theirs != options && !sameFile(file, outputFile) {
+			return nil, fmt.Errorf(
+				"%v was generated with options '%v' but this run uses '%v', generate every config of a package with the same options",
+				file,
+				theirs,
+				options,
+			)
+		}
+	}
+
+	names := make(map[string]bool)
+	for name, decl := range p.Declared {
+		if decl.Generated && !sameFile(decl.Pos.Filename, outputFile) {
+			names[name] = true
+		}
+	}
+
+	return names, nil
+}
+
+// checkClashes fails if src, the file we generated, declares a name also
+// declared by hand in our package. Dropping either would leave code
+// using the other one, which only fails to compile later on.
+func (p *PackageTypes) checkClashes(src []byte, outputFile string) error {
+	fset := token.NewFileSet()
+	file, err := parser.ParseFile(fset, outputFile, src, 0)
+	if err != nil {
+		return err
+	}
+
+	ours := make(map[string]Decl)
+	addDeclared(ours, fset, file)
+
+	var clashes []string
+	for name, decl := range ours {
+		user, found := p.Declared[name]
+		if !found || user.Generated || sameFile(user.Pos.Filename, outputFile) {
+			continue
+		}
+
+		clashes = append(clashes, fmt.Sprintf(
+			"generated %v %v clashes with %v %v declared at %v",
+			decl.Kind,
+			name,
+			user.Kind,
+			name,
+			user.Pos,
+		))
+	}
+
+	if len(clashes) == 0 {
+		return nil
+	}
+
+	slices.Sort(clashes)
+	return errors.New(strings.Join(clashes, "; "))
+}
+
+func sameFile(a, b string) bool {
+	absA, _ := filepath.Abs(a)
+	absB, _ := filepath.Abs(b)
+	return absA == absB
+}
+
+// rewriteBody drops every top level declaration of body named in drop,
+// along with the methods of dropped types. Uses of the dropped names in
+// qualify are replaced by the envrt name they map to. Header is the
+// package clause and imports of body, the paths of the imports still used
+// are returned.
+func rewriteBody(header, body []byte, drop map[string]bool, qualify map[string]string) ([]byte, map[string]bool, error) {
+	fset := token.NewFileSet()
+	file, err := parser.ParseFile(fset, "", append(header, body...), parser.ParseComments)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	// names are resolved before anything is dropped, so only our own
+	// declarations are qualified and never a local or a field
+	pkg, info := resolveFiles(fset, file)
+
+	droppedTypes := make(map[string]bool)
+	for _, decl := range file.Decls {
+		if d, ok := decl.(*ast.GenDecl); ok && d.Tok == token.TYPE {
+			for _, spec := range d.Specs {
+				droppedTypes[specName(spec)] = drop[specName(spec)]
+			}
+		}
+	}
+
+	// comments of dropped decls would otherwise be printed in their place
+	var dropped []posRange
+	decls := file.Decls[:0]
+	for _, decl := range file.Decls {
+		switch d := decl.(type) {
+		case *ast.FuncDecl:
+			name := declName(d)
+			typeName, _, _ := strings.Cut(name, ".")
+			if drop[name] || droppedTypes[typeName] {
+				logLine("already declared:", name)
+				dropped = append(dropped, newPosRange(d.Doc, d))
+				continue
+			}
+		case *ast.GenDecl:
+			// imports are written again with only those still used
+			if d.Tok == token.IMPORT {
+				continue
+			}
+
+			specs := d.Specs[:0]
+			for _, spec := range d.Specs {
+				if name := specName(spec); drop[name] {
+					logLine("already declared:", name)
+					dropped = append(dropped, newPosRange(specDoc(spec), spec))
+					continue
+				}
+
+				specs = append(specs, spec)
+			}
+
+			if len(specs) == 0 {
+				dropped = append(dropped, newPosRange(d.Doc, d))
+				continue
+			}
+
+			d.Specs = specs
+		}
+
+		decls = append(decls, decl)
+	}
+	file.Decls = decls
+
+	qualified := false
+	for ident, obj := range info.Uses {
+		if name, found := qualify[obj.Name()]; found && drop[obj.Name()] && obj.Parent() == pkg.Scope() {
+			ident.Name = "envrt." + name
+			qualified = true
+		}
+	}
+
+	comments := file.Comments[:0]
+	for _, group := range file.Comments {
+		if !within(group, dropped) {
+			comments = append(comments, group)
+		}
+	}
+	file.Comments = comments
+
+	used := make(map[string]bool)
+	if qualified {
+		used[runtimeImport] = true
+	}
+
+	ast.Inspect(file, func(n ast.Node) bool {
+		if ident, ok := n.(*ast.Ident); ok {
+			if pkgName, ok := info.Uses[ident].(*types.PkgName); ok {
+				used[pkgName.Imported().Path()] = true
+			}
+		}
+
+		return true
+	})
+
+	var buf bytes.Buffer
+	if err := printer.Fprint(&buf, fset, file); err != nil {
+		return nil, nil, err
+	}
+
+	printedHeader := fmt.Sprintf("package %v\n\n", file.Name.Name)
+	return bytes.TrimPrefix(buf.Bytes(), []byte(printedHeader)), used, nil
+}
+
+func specName(spec ast.Spec) string {
+	switch s := spec.(type) {
+	case *ast.TypeSpec:
+		return s.Name.Name
+	case *ast.ValueSpec:
+		return s.Names[0].Name
+	}
+
+	return ""
+}
+
+func specDoc(spec ast.Spec) *ast.CommentGroup {
+	switch s := spec.(type) {
+	case *ast.TypeSpec:
+		return s.Doc
+	case *ast.ValueSpec:
+		return s.Doc
+	}
+
+	return nil
+}
+
+// posRange is the source range of a decl including its doc comment.
+type posRange struct {
+	from, to token.Pos
+}
+
+func newPosRange(doc *ast.CommentGroup, n ast.Node) posRange {
+	if doc != nil {
+		return posRange{from: doc.Pos(), to: n.End()}
+	}
+
+	return posRange{from: n.Pos(), to: n.End()}
+}
+
+func within(group *ast.CommentGroup, ranges []posRange) bool {
+	for _, r := range ranges {
+		if group.Pos() >= r.from && group.End() <= r.to {
+			return true
+		}
+	}
+
+	return false
+}
@@ -0,0 +1,160 @@
+package main
+
+import (
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestMultipleRoots(t *testing.T) {
+	generated := mustGenerate(t, `package main
+
+//genenv:prefix API
+type ApiConfig struct {
+	Port int `+"`default:\"80\"`"+`
+	Db   DbConfig
+}
+
+//genenv:prefix WORKER
+type WorkerConfig struct {
+	Threads int `+"`default:\"4\"`"+`
+	Db      DbConfig
+}
+
+type DbConfig struct {
+	File string `+"`default:\"db.sqlite\"`"+`
+}
+`, GenConfig{ConfigTypes: []string{"ApiConfig", "WorkerConfig"}})
+
+	hasAll(t, generated,
+		"func NewApiConfig() (*ApiConfig, error) {",
+		"func NewWorkerConfig() (*WorkerConfig, error) {",
+		`const ApiConfigPrefix = "API"`,
+		`const WorkerConfigPrefix = "WORKER"`,
+		`vDb, err := loadDbConfig(ld, "API_DB")`,
+		`vDb, err := loadDbConfig(ld, "WORKER_DB")`,
+	)
+
+	// nested types shared by several roots are written once
+	if n := strings.Count(generated, "func loadDbConfig("); n != 1 {
+		t.Errorf("expected loadDbConfig once, got %v", n)
+	}
+}
+
+func TestMultipleRootErrors(t *testing.T) {
+	src := "package main\n\ntype Config struct {\nPort int\nOther Other\n}\n\ntype Other struct {\nHost string\n}\n"
+
+	_, err := generate(t, src, GenConfig{ConfigTypes: []string{"Config", "Other"}})
+	if err == nil || !strings.Contains(err.Error(), "config type 'Other' is also nested in 'Config'") {
+		t.Fatalf("expected a nested root error, got %v", err)
+	}
+
+	_, err = generate(t, src, GenConfig{ConfigTypes: []string{"Config", "Other"}, Prefix: "APP"})
+	if err == nil || !strings.Contains(err.Error(), "--prefix can only be used with a single config type") {
+		t.Fatalf("expected a prefix error, got %v", err)
+	}
+}
+
+func TestSeparateRuns(t *testing.T) {
+	dir := t.TempDir()
+	writeSource(t, dir, "api.go", "package main\n\ntype ApiConfig struct {\nPort int `default:\"80\"`\nDb DbConfig\n}\n\ntype DbConfig struct {\nFile string\n}\n")
+	writeSource(t, dir, "worker.go", "package main\n\ntype WorkerConfig struct {\nThreads int `default:\"4\"`\nDb DbConfig\n}\n")
+
+	api, err := generateIn(t, dir, GenConfig{ConfigTypes: []string{"ApiConfig"}, GoOutputFile: filepath.Join(dir, "api_gen.go")})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	worker, err := generateIn(t, dir, GenConfig{ConfigTypes: []string{"WorkerConfig"}, GoOutputFile: filepath.Join(dir, "worker_gen.go")})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	hasAll(t, api, "// Code generated by genenv. DO NOT EDIT.", "func loadDbConfig(", "func parseRequired[")
+
+	// the second run uses what the first one generated
+	hasAll(t, worker, "func NewWorkerConfig() (*WorkerConfig, error) {", `vDb, err := loadDbConfig(ld, "DB")`)
+	hasNone(t, worker, "func loadDbConfig(", "func parseRequired[", "type Lookuper interface")
+
+	// running again replaces our own output instead of deduping against it
+	again, err := generateIn(t, dir, GenConfig{ConfigTypes: []string{"ApiConfig"}, GoOutputFile: filepath.Join(dir, "api_gen.go")})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	hasAll(t, again, "func loadDbConfig(")
+}
+
+func TestUserDeclarationClash(t *testing.T) {
+	dir := t.TempDir()
+	writeSource(t, dir, "config.go", "package main\n\ntype Config struct {\nPort int `default:\"80\"`\n}\n")
+	writeSource(t, dir, "change.go", "package main\n\ntype Change struct {\nID int\n}\n\nfunc (c *Config) String() string {\nreturn \"config\"\n}\n")
+
+	_, err := generateIn(t, dir, GenConfig{With: []string{"compare"}})
+	if err == nil {
+		t.Fatal("expected a clash error")
+	}
+
+	for _, want := range []string{
+		"generated method Config.String clashes with method Config.String declared at " + filepath.Join(dir, "change.go") + ":7:18",
+		"generated type Change clashes with type Change declared at " + filepath.Join(dir, "change.go") + ":3:6",
+	} {
+		if !strings.Contains(err.Error(), want) {
+			t.Errorf("expected %q in %v", want, err)
+		}
+	}
+}
+
+func TestOtherGeneratorClash(t *testing.T) {
+	dir := t.TempDir()
+	writeSource(t, dir, "config.go", "package main\n\ntype Config struct {\nPort int `default:\"80\"`\n}\n")
+	writeSource(t, dir, "lookup_string.go", "// Code generated by \"stringer -type=Lookuper\"; DO NOT EDIT.\n\npackage main\n\ntype Lookuper int\n")
+
+	_, err := generateIn(t, dir, GenConfig{})
+	want := "generated type Lookuper clashes with type Lookuper declared at " + filepath.Join(dir, "lookup_string.go") + ":5:6"
+	if err == nil || !strings.Contains(err.Error(), want) {
+		t.Fatalf("expected %q, got %v", want, err)
+	}
+}
+
+func TestSeparateRunOptions(t *testing.T) {
+	dir := t.TempDir()
+	writeSource(t, dir, "api.go", "package main\n\ntype ApiConfig struct {\nPort int `default:\"80\"`\nDb DbConfig\n}\n\ntype DbConfig struct {\nFile string\n}\n")
+	writeSource(t, dir, "worker.go", "package main\n\ntype WorkerConfig struct {\nThreads int `default:\"4\"`\nDb DbConfig\n}\n")
+
+	apiFile := filepath.Join(dir, "api_gen.go")
+	api, err := generateIn(t, dir, GenConfig{ConfigTypes: []string{"ApiConfig"}, GoOutputFile: apiFile, Aggregate: true, With: []string{"compare", "compare"}})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	hasAll(t, api, "// Code generated by genenv. DO NOT EDIT.\n// genenv options: --aggregate --with=compare\n")
+
+	for _, cfg := range []GenConfig{
+		{},
+		{Aggregate: true, Runtime: true},
+		{Aggregate: true, With: []string{"compare", "watcher"}},
+	} {
+		cfg.ConfigTypes = []string{"WorkerConfig"}
+		cfg.GoOutputFile = filepath.Join(dir, "worker_gen.go")
+
+		_, err := generateIn(t, dir, cfg)
+		want := apiFile + " was generated with options '--aggregate --with=compare' but this run uses '" + genOptions(cfg) + "'"
+		if err == nil || !strings.Contains(err.Error(), want) {
+			t.Errorf("expected %q, got %v", want, err)
+		}
+	}
+
+	// the same options in any order share declarations again
+	worker, err := generateIn(t, dir, GenConfig{ConfigTypes: []string{"WorkerConfig"}, GoOutputFile: filepath.Join(dir, "worker_gen.go"), With: []string{"compare"}, Aggregate: true})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	hasNone(t, worker, "func loadDbConfig(")
+
+	// changing the options of one run now conflicts with the other file
+	if _, err := generateIn(t, dir, GenConfig{ConfigTypes: []string{"ApiConfig"}, GoOutputFile: apiFile, Aggregate: true}); err == nil {
+		t.Fatal("expected the worker options to be checked")
+	}
+}
@@ -0,0 +1,143 @@
+package main
+
+import (
+	"bytes"
+	"io"
+	"strings"
+)
+
+// writeDefaults writes adding the default tag of each of our keys to a
+// lookup, and building our type from them alone.
+func (b *StructBuilder) writeDefaults(w io.Writer) error {
+	if b.rootType {
+		writeF(
+			w,
+			`// %[1]vDefaults returns the default tag of every key in %[1]v.
+			func %[1]vDefaults() MapLookup {
+			d := MapLookup{}
+			add%[1]vDefaults(d)
+			return d
+			}
+
+			// Default%[1]v returns a %[1]v built only from default tags, without
+			// reading the environment. Keys without a default are left as their
+			// zero value and no rules or Validate hooks are run, so it only fails
+			// on defaults referencing a key without one.
+			func Default%[1]v() (*%[1]v, error) {
+			return default%[1]v(%[2]v)
+			}
+
+			func add%[1]vDefaults(d MapLookup) {
+			`,
+			b.name,
+			b.loaderExpr("MapLookup{}"),
+		)
+	} else {
+		writeF(w, "func add%vDefaults(d MapLookup, prefix string) {\n", b.name)
+	}
+
+	for _, f := range b.order {
+		if f.customType {
+			writeF(w, "add%vDefaults(d, %v)\n", f.typeName, f.keyExpr())
+		} else if f.hasDefault {
+			writeF(w, "d[%v] = %q\n", f.keyExpr(), f.defaultValue)
+		}
+	}
+
+	writeF(w, "}\n\n")
+	return b.writeDefaultLoader(w)
+}
+
+// writeDefaultLoader writes parsing only the default tags of our keys and
+// those of our nested types. Every error is collected as the defaults are
+// already checked while generating.
+func (b *StructBuilder) writeDefaultLoader(w io.Writer) error {
+	b.templates.Use("ConfigErrors")
+	if b.rootType {
+		writeF(w, "func default%[1]v(ld *loader) (*%[1]v, error) {\n", b.name)
+	} else {
+		writeF(w, "func default%[1]v(ld *loader, prefix string) (*%[1]v, error) {\n", b.name)
+	}
+
+	var body bytes.Buffer
+	for _, f := range b.order {
+		switch {
+		case f.customType && f.pointer:
+			writeF(&body, "c.%v, err = default%v(ld, %v)", f.goPath, f.typeName, f.keyExpr())
+		case f.customType:
+			localName := "v" + strings.ReplaceAll(f.goPath, ".", "")
+			writeF(&body, "%v, err := default%v(ld, %v)", localName, f.typeName, f.keyExpr())
+			writeErrCheck(&body, true)
+			writeF(&body, "\nc.%v = *%v", f.goPath, localName)
+		case f.hasDefault:
+			parseFunc, convFunc, err := f.parseFuncsOf(false)
+			if err != nil {
+				return err
+			}
+
+			writeF(&body, "c.%v, err = %v(ld, %v, %v)", f.goPath, parseFunc, f.keyExpr(), convFunc)
+			f.writeDescribe(&body)
+		default:
+			continue
+		}
+
+		if !f.customType || f.pointer {
+			writeErrCheck(&body, true)
+		}
+		writeF(&body, "\n\n")
+	}
+
+	// err is only used by fields, so types without defaults skip it
+	errVar := ""
+	if body.Len() > 0 {
+		errVar = "err error\n"
+	}
+
+	writeF(w, "var (\n%verrs ConfigErrors\n)\n\nc := &%v{}\n\n", errVar, b.name)
+	body.WriteTo(w)
+	writeF(w, "return c, errs.Err()\n}\n\n")
+
+	return nil
+}
+
+// writeFromSources writes the constructor merging each of our supported
+// sources in their documented order.
+func (b *StructBuilder) writeFromSources(w io.Writer) {
+	if !b.rootType {
+		return
+	}
+
+	b.templates.Use("LoadJSONFile", "NamedLookup", "ChainLookup", "OSLookup")
+	writeF(
+		w,
+		`// New%[1]vFromSources loads %[1]v from several sources, each one only
+		// overriding the keys it sets in the sources before it:
+		//
+		//  1. default tags
+		//  2. the JSON file at jsonPath, skipped if empty
+		//  3. the environment
+		//  4. flags, skipped if nil
+		func New%[1]vFromSources(jsonPath string, flags Lookuper) (*%[1]v, error) {
+			var layers ChainLookup
+			if flags != nil {
+				layers = append(layers, flags)
+			}
+
+			layers = append(layers, OSLookup)
+
+			if jsonPath != "" {
+				file, err := LoadJSONFile(jsonPath)
+				if err != nil {
+					return nil, err
+				}
+
+				layers = append(layers, NamedLookup{Name: "file " + jsonPath, Lookuper: file})
+			}
+
+			return New%[1]vFrom(layers)
+		}
+
+		`,
+		b.name,
+	)
+}
@@ -1,9 +1,11 @@
 package main
 
 import (
+	"fmt"
 	"go/ast"
 	"go/doc"
 	"io"
+	"slices"
 	"strings"
 )
 
@@ -15,84 +17,167 @@ type StructBuilder struct {
 	name      string
 	rootType  bool
 	buildType string
+	aggregate bool
+	strict    bool
+	flags     []string
+	with      []string
+	keyPrefix string
+	pkgName   string
 
 	queue       *QueueCache
-	parsers     *ParserCache
 	errs        *ErrorCache
 	importCache *ImportCache
+	templates   *TemplateCache
 
 	fields map[string]*Field
+	order  []*Field
+	rules  []Rule
+
+	// inlineTypes maps the go path of each inline field to its type
+	inlineTypes map[string]string
+	// inlinePaths lists the go path of each inline field, deeper fields
+	// before the field they are promoted through
+	inlinePaths []string
+	// validateCalls are the Validate hooks of our inline types and then
+	// our own, in the order they are called
+	validateCalls []ValidateCall
 }
 
 func NewStructBuilder(
 	tpe *doc.Type,
 	pkgTypes *PackageTypes,
 	imports map[string]string,
-	rootTypeName string,
+	cfg GenConfig,
 	queue *QueueCache,
-	parsers *ParserCache,
 	errs *ErrorCache,
 	importCache *ImportCache,
+	templates *TemplateCache,
 ) (*StructBuilder, error) {
 	b := &StructBuilder{
 		pkgTypes:    pkgTypes,
 		us:          tpe,
-		rootType:    tpe.Name == rootTypeName,
+		rootType:    slices.Contains(cfg.ConfigTypes, tpe.Name),
+		aggregate:   cfg.Aggregate,
+		strict:      cfg.Strict,
+		flags:       cfg.Flags,
+		with:        cfg.With,
 		name:        tpe.Name,
 		queue:       queue,
-		parsers:     parsers,
 		errs:        errs,
 		imports:     imports,
 		importCache: importCache,
+		templates:   templates,
+		pkgName:     cfg.PackageName,
 		fields:      make(map[string]*Field),
+		inlineTypes: make(map[string]string),
 	}
 
-	for _, spec := range b.us.Decl.Specs {
-		typeSpec, _ := spec.(*ast.TypeSpec)
-		structType, ok := typeSpec.Type.(*ast.StructType)
+	if b.rootType {
+		b.keyPrefix = cfg.Prefix
+		if b.keyPrefix == "" {
+			b.keyPrefix = prefixDirective(tpe)
+		}
+	}
 
-		if !ok {
+	var found []promotedField
+	if err := b.collectFields(b.us, "", "", 0, map[string]bool{}, &found); err != nil {
+		return nil, err
+	}
+
+	// resolve promoted names the same way go does, the shallowest field
+	// wins and fields at the same depth are ambiguous and dropped
+	depths := make(map[string][]int)
+	for _, pf := range found {
+		depths[pf.field.varName] = append(depths[pf.field.varName], pf.depth)
+	}
+
+	for _, pf := range found {
+		newField := pf.field
+		if !isShallowest(pf.depth, depths[newField.varName]) {
+			logLine("skipping shadowed field:", newField.goPath)
 			continue
 		}
 
-		for _, field := range structType.Fields.List {
-			newField, err := NewField(field, b.pkgTypes, b.imports, b.rootType, b.queue, b.parsers, b.errs, b.importCache)
-			if err != nil {
-				return nil, err
-			}
-
-			b.fields[newField.varName] = newField
+		b.fields[newField.varName] = newField
+		b.order = append(b.order, newField)
 
-			logLine("var name:", newField.varName)
-			if newField.buildType != "" {
-				logLine("found build type:", newField.buildType)
-				b.buildType = newField.buildType
-			}
+		logLine("var name:", newField.varName)
+		if newField.buildType != "" {
+			logLine("found build type:", newField.buildType)
+			b.buildType = newField.buildType
 		}
 	}
 
+	if err := b.resolveRules(); err != nil {
+		return nil, err
+	}
+
+	if err := b.resolveValidateHooks(); err != nil {
+		return nil, err
+	}
+
 	return b, nil
 }
 
 func (b *StructBuilder) Write(w io.Writer) error {
+	// the plain constructors read from the os, while the From variants
+	// take any Lookuper, such as a map or a LookupFunc. Both load with the
+	// defaults of every nested type so references between keys can always
+	// be resolved.
+	b.templates.Use("Lookuper", "LookupFunc", "MapLookup", "OSLookup", "Loader")
+	b.writePrefix(w)
 	if b.rootType {
 		writeF(w,
-			"func New%v() (*%v, error) {\n",
-			b.name,
+			`func New%[1]v() (*%[1]v, error) {
+			return New%[1]vFrom(OSLookup)
+			}
+
+			func New%[1]vFrom(l Lookuper) (*%[1]v, error) {
+			c, _, err := Load%[1]v(l)
+			return c, err
+			}
+
+			func load%[1]v(ld *loader) (*%[1]v, error) {
+			`,
 			b.name,
 		)
 	} else {
 		writeF(w,
-			"func New%v(prefix string) (*%v, error) {\n",
-			b.name,
+			`func New%[1]v(prefix string) (*%[1]v, error) {
+			return New%[1]vFrom(OSLookup, prefix)
+			}
+
+			func New%[1]vFrom(l Lookuper, prefix string) (*%[1]v, error) {
+			d := MapLookup{}
+			add%[1]vDefaults(d, prefix)
+			return load%[1]v(&loader{Lookup: l, Defaults: d}, prefix)
+			}
+
+			func load%[1]v(ld *loader, prefix string) (*%[1]v, error) {
+			`,
 			b.name,
 		)
 	}
 
-	writeF(w,
-		"var err error\n\nc := &%v{}\n\n",
-		b.name,
-	)
+	if b.aggregate {
+		b.templates.Use("ConfigErrors")
+		// err is only used by fields, so empty structs skip it
+		errVar := ""
+		if len(b.order) > 0 {
+			errVar = "err error\n"
+		}
+
+		writeF(w,
+			"var (\n%verrs ConfigErrors\n)\n\nc := &%v{}\n\n",
+			errVar,
+			b.name,
+		)
+	} else {
+		writeF(w,
+			"var err error\n\nc := &%v{}\n\n",
+			b.name,
+		)
+	}
 
 	f, hasTypeField := b.fields["Type"]
 	if hasTypeField {
@@ -102,19 +187,55 @@ func (b *StructBuilder) Write(w io.Writer) error {
 		}
 	}
 
-	for _, f := range b.fields {
-		f.hasTypeField = hasTypeField
+	for _, f := range b.order {
 		if f.varName == "Type" {
 			continue
 		}
 
+		// build type options are only loaded when selected
+		if hasTypeField {
+			f.loadIf = fmt.Sprintf("c.Type == %q", f.envKey)
+		}
+
 		err := f.Write(w)
 		if err != nil {
 			return err
 		}
 	}
 
-	writeF(w, "\nreturn c, err\n}\n\n")
+	b.writeRules(w)
+	b.writeValidateHook(w)
+	b.writeStrictCheck(w)
+
+	if b.aggregate {
+		writeF(w, "\nreturn c, errs.Err()\n}\n\n")
+	} else {
+		writeF(w, "\nreturn c, err\n}\n\n")
+	}
+
+	b.writePatterns(w)
+	if err := b.writeDefaults(w); err != nil {
+		return err
+	}
+	b.writeFromFiles(w)
+	b.writeFromSources(w)
+	b.writeKeys(w)
+	b.writeFlags(w)
+	b.writeSources(w)
+	b.writeRedacted(w)
+	if err := b.writeToEnv(w); err != nil {
+		return err
+	}
+	b.writeFixedChanges(w)
+	if b.writes("compare") {
+		b.writeClone(w)
+		b.writeEqual(w)
+		if err := b.writeDiff(w); err != nil {
+			return err
+		}
+	}
+	b.writeWatcher(w)
+	b.writeHandler(w)
 
 	if b.buildType != "" {
 		logLine("using build type:", b.buildType)
@@ -136,12 +257,12 @@ func (b *StructBuilder) Write(w io.Writer) error {
 			"switch c.Type {\n",
 		)
 
-		for n, f := range b.fields {
-			if n == "Type" {
+		for _, f := range b.order {
+			if f.varName == "Type" {
 				continue
 			}
 
-			newFuncName := strings.TrimSuffix(n, "Config")
+			newFuncName := strings.TrimSuffix(f.varName, "Config")
 
 			writeF(
 				w,
@@ -152,10 +273,7 @@ func (b *StructBuilder) Write(w io.Writer) error {
 			)
 		}
 
-		b.errs.Add("ErrInvalidBuildType", ErrorDef{
-			VarName: "ErrInvalidBuildType",
-			Desc:    "invalid build type",
-		})
+		b.templates.Use("ErrInvalidBuildType")
 		writeF(
 			w,
 			"default:\nreturn nil, fmt.Errorf(\"%%w: %%v\", %v, c.Type)\n}\n}\n\n",
@@ -165,3 +283,119 @@ func (b *StructBuilder) Write(w io.Writer) error {
 
 	return nil
 }
+
+// writes checks if the optional output was asked for with --with.
+func (b *StructBuilder) writes(output string) bool {
+	return slices.Contains(b.with, output)
+}
+
+// loaderExpr returns the go expression of the loader our root type is
+// loaded with from lookup.
+func (b *StructBuilder) loaderExpr(lookup string) string {
+	if b.keyPrefix == "" {
+		return fmt.Sprintf("&loader{Lookup: %v, Defaults: %vDefaults()}", lookup, b.name)
+	}
+
+	return fmt.Sprintf("&loader{Lookup: %v, Defaults: %[2]vDefaults(), Prefix: %[2]vPrefix}", lookup, b.name)
+}
+
+type promotedField struct {
+	field *Field
+	depth int
+}
+
+// collectFields walks the struct fields of tpe in declaration order,
+// expanding inline fields into the fields of their type.
+func (b *StructBuilder) collectFields(
+	tpe *doc.Type,
+	path string,
+	keyPrefix string,
+	depth int,
+	visiting map[string]bool,
+	found *[]promotedField,
+) error {
+	if visiting[tpe.Name] {
+		return fmt.Errorf("inline cycle found at type: '%v'", tpe.Name)
+	}
+
+	visiting[tpe.Name] = true
+	defer delete(visiting, tpe.Name)
+
+	for _, spec := range tpe.Decl.Specs {
+		typeSpec, _ := spec.(*ast.TypeSpec)
+		structType, ok := typeSpec.Type.(*ast.StructType)
+
+		if !ok {
+			continue
+		}
+
+		for _, field := range structType.Fields.List {
+			if reason := skipReason(field, b.pkgTypes); reason != "" {
+				logLine("skipping field:", path+fieldName(field), "-", reason)
+				continue
+			}
+
+			newField, err := NewField(field, b.pkgTypes, b.imports, b.rootType, b.queue, b.errs, b.importCache, b.templates)
+			if err != nil {
+				return err
+			}
+
+			newField.goPath = path + newField.goPath
+			newField.rootPrefix = b.keyPrefix
+			newField.envKey = joinKey(keyPrefix, newField.envKey)
+			newField.aggregate = b.aggregate
+			newField.structName = b.name
+
+			if !newField.inline {
+				*found = append(*found, promotedField{field: newField, depth: depth})
+				continue
+			}
+
+			logLine("inlining field:", newField.goPath)
+			b.inlineTypes[newField.goPath] = newField.typeName
+			childPrefix := keyPrefix
+			if newField.inlinePrefix != "" {
+				childPrefix = joinKey(keyPrefix, newField.inlinePrefix)
+			}
+
+			err = b.collectFields(
+				b.pkgTypes.DocTypes[newField.typeName],
+				newField.goPath+".",
+				childPrefix,
+				depth+1,
+				visiting,
+				found,
+			)
+			if err != nil {
+				return err
+			}
+
+			b.inlinePaths = append(b.inlinePaths, newField.goPath)
+		}
+	}
+
+	return nil
+}
+
+func isShallowest(depth int, all []int) bool {
+	count := 0
+	for _, d := range all {
+		if d < depth {
+			return false
+		}
+
+		if d == depth {
+			count++
+		}
+	}
+
+	return count == 1
+}
+
+func joinKey(prefix, key string) string {
+	if prefix == "" {
+		return key
+	}
+
+	return prefix + "_" + key
+}
@@ -0,0 +1,150 @@
+package main
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestInlineFields(t *testing.T) {
+	generated := mustGenerate(t, `package main
+
+type Config struct {
+	Logging
+	Level2 string
+	Db     DbConfig    `+"`env:\"DB,inline\"`"+`
+	Cache  CacheConfig `+"`env:\",squash\"`"+`
+	Other  OtherConfig `+"`env:\"OTHER\"`"+`
+	Named  `+"`env:\"NAMED\"`"+`
+	A
+	B
+}
+
+type Logging struct {
+	Level  string `+"`default:\"info\"`"+`
+	Level2 string
+}
+
+type DbConfig struct {
+	File string
+}
+
+type CacheConfig struct {
+	Size int
+}
+
+type OtherConfig struct {
+	Name string
+}
+
+type Named struct {
+	Value string
+}
+
+type A struct {
+	Dup string
+}
+
+type B struct {
+	Dup string
+}
+`, GenConfig{})
+
+	hasAll(t, generated,
+		// embedded structs are promoted without a prefix
+		`c.Logging.Level, err = parseOptional(ld, "LEVEL", convString)`,
+		// inline fields are loaded under their env name, squash without one
+		`c.Db.File, err = parseRequired(ld, "DB_FILE", convString)`,
+		`c.Cache.Size, err = parseRequired(ld, "SIZE", convInt)`,
+		// named fields and embedded fields given an env name stay nested
+		`vOther, err := loadOtherConfig(ld, "OTHER")`,
+		`vNamed, err := loadNamed(ld, "NAMED")`,
+		// the shallowest field wins like go
+		`c.Level2, err = parseRequired(ld, "LEVEL2", convString)`,
+	)
+
+	// fields at the same depth are ambiguous and not loaded
+	hasNone(t, generated, "c.Logging.Level2,", `"DUP"`)
+}
+
+func TestInlineFieldErrors(t *testing.T) {
+	for _, tc := range []struct {
+		name    string
+		src     string
+		wantErr string
+	}{
+		{
+			name:    "pointer",
+			src:     "type Config struct {\nDb *DbConfig `env:\"DB,inline\"`\n}\n\ntype DbConfig struct {\nFile string\n}\n",
+			wantErr: "inline requires a struct value for field: 'Db'",
+		},
+		{
+			name:    "not a struct",
+			src:     "type Config struct {\nPort int `env:\"PORT,squash\"`\n}\n",
+			wantErr: "inline requires a struct value for field: 'Port'",
+		},
+		{
+			name:    "cycle",
+			src:     "type Config struct {\nInner\n}\n\ntype Inner struct {\nOuter `env:\",inline\"`\n}\n\ntype Outer struct {\nInner `env:\",inline\"`\n}\n",
+			wantErr: "inline cycle found at type: 'Inner'",
+		},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			_, err := generate(t, "package main\n\n"+tc.src, GenConfig{})
+			if err == nil || !strings.Contains(err.Error(), tc.wantErr) {
+				t.Fatalf("expected %q, got %v", tc.wantErr, err)
+			}
+		})
+	}
+}
+
+func TestGeneratedLookupers(t *testing.T) {
+	src := `package main
+
+type Config struct {
+	Host string
+	Port int ` + "`default:\"80\"`" + `
+}
+`
+
+	got := runGenerated(t, src, GenConfig{}, `package main
+
+import (
+	"fmt"
+	"os"
+)
+
+func main() {
+	os.Setenv("HOST", "env")
+	c, err := NewConfigFrom(LookupFunc(os.LookupEnv))
+	fmt.Println(c.Host, c.Port, err)
+
+	c, err = NewConfigFrom(MapLookup{"HOST": "map", "PORT": "8080"})
+	fmt.Println(c.Host, c.Port, err)
+}
+`)
+
+	if want := "env 80 <nil>\nmap 8080 <nil>"; got != want {
+		t.Fatalf("got:\n%v\nwant:\n%v", got, want)
+	}
+
+	// the same lookups come from envrt with a runtime
+	got = runGenerated(t, src, GenConfig{Runtime: true}, `package main
+
+import (
+	"fmt"
+	"os"
+
+	"github.com/miniscruff/genenv/envrt"
+)
+
+func main() {
+	os.Setenv("HOST", "env")
+	c, err := NewConfigFrom(envrt.LookupFunc(os.LookupEnv))
+	fmt.Println(c.Host, c.Port, err)
+}
+`)
+
+	if want := "env 80 <nil>"; got != want {
+		t.Fatalf("got:\n%v\nwant:\n%v", got, want)
+	}
+}
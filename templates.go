@@ -1,4 +1,316 @@
 package main
 
-const (
+import (
+	"bytes"
+	"embed"
+	"fmt"
+	"go/ast"
+	"go/parser"
+	"go/printer"
+	"go/token"
+	"go/types"
+	"path"
+	"strconv"
+	"strings"
+	"unicode"
 )
+
+// runtimeImport is the package providing every template when generating
+// with --runtime.
+const runtimeImport = "github.com/miniscruff/genenv/envrt"
+
+//go:embed envrt/*.go
+var runtimeFiles embed.FS
+
+// unexportedTemplates are the envrt names written unexported into a
+// generated file, as only the generated code calls them.
+var unexportedTemplates = map[string]bool{
+	"ChangeKey":          true,
+	"ConfigEntries":      true,
+	"ConfigHandler":      true,
+	"ConvBool":           true,
+	"ConvInt":            true,
+	"ConvString":         true,
+	"ConvTimeDuration":   true,
+	"CountTrue":          true,
+	"DescribeField":      true,
+	"EnvironOf":          true,
+	"Explain":            true,
+	"ExpandRefs":         true,
+	"FlagAdder":          true,
+	"FlagLookup":         true,
+	"FlagUsage":          true,
+	"FormatBool":         true,
+	"FormatInt":          true,
+	"FormatSlice":        true,
+	"FormatString":       true,
+	"FormatTimeDuration": true,
+	"KnownKeys":          true,
+	"LogPtr":             true,
+	"Loader":             true,
+	"LookupEnv":          true,
+	"ModTimes":           true,
+	"ModTimesChanged":    true,
+	"ParseOptional":      true,
+	"ParseRequired":      true,
+	"ParseSliceOptional": true,
+	"ParseSliceRequired": true,
+	"Redact":             true,
+	"SplitSlice":         true,
+	"StdFlagChanged":     true,
+}
+
+// inlineName returns the name of an envrt declaration when it is written
+// into a generated file.
+func inlineName(name string) string {
+	if !unexportedTemplates[name] {
+		return name
+	}
+
+	return unexported(name)
+}
+
+// unexported returns name starting with a lower case letter.
+func unexported(name string) string {
+	for i, r := range name {
+		return string(unicode.ToLower(r)) + name[i+len(string(r)):]
+	}
+
+	return name
+}
+
+// runtimeTemplates holds every declaration of envrt keyed by its envrt
+// name, so generated files carry the same code as the runtime instead of
+// a copy of it. Errors are templates without code.
+var runtimeTemplates = mustLoadTemplates()
+
+func mustLoadTemplates() map[string]Template {
+	templates, err := loadTemplates()
+	if err != nil {
+		panic(fmt.Sprintf("loading envrt templates: %v", err))
+	}
+
+	return templates
+}
+
+// stubImporter imports every package as an empty one, enough to resolve
+// the names of a file without type checking what it imports.
+type stubImporter struct{}
+
+func (stubImporter) Import(importPath string) (*types.Package, error) {
+	pkg := types.NewPackage(importPath, path.Base(importPath))
+	pkg.MarkComplete()
+	return pkg, nil
+}
+
+// resolveFiles resolves every name used in files, type errors are
+// expected as imports are stubbed and are ignored.
+func resolveFiles(fset *token.FileSet, files ...*ast.File) (*types.Package, *types.Info) {
+	info := &types.Info{
+		Defs: make(map[*ast.Ident]types.Object),
+		Uses: make(map[*ast.Ident]types.Object),
+	}
+	conf := types.Config{
+		Importer: stubImporter{},
+		Error:    func(error) {},
+	}
+	pkg, _ := conf.Check(files[0].Name.Name, fset, files, info)
+
+	return pkg, info
+}
+
+// templateDecl is a single envrt declaration, types include their methods.
+type templateDecl struct {
+	name  string
+	nodes []ast.Node
+}
+
+func loadTemplates() (map[string]Template, error) {
+	entries, err := runtimeFiles.ReadDir("envrt")
+	if err != nil {
+		return nil, err
+	}
+
+	fset := token.NewFileSet()
+	var files []*ast.File
+	for _, entry := range entries {
+		if strings.HasSuffix(entry.Name(), "_test.go") {
+			continue
+		}
+
+		src, err := runtimeFiles.ReadFile("envrt/" + entry.Name())
+		if err != nil {
+			return nil, err
+		}
+
+		file, err := parser.ParseFile(fset, entry.Name(), src, parser.ParseComments)
+		if err != nil {
+			return nil, err
+		}
+
+		files = append(files, file)
+	}
+
+	pkg, info := resolveFiles(fset, files...)
+	templates := make(map[string]Template)
+	decls := make(map[string]*templateDecl)
+	var order []*templateDecl
+
+	for _, file := range files {
+		for _, decl := range file.Decls {
+			switch d := decl.(type) {
+			case *ast.FuncDecl:
+				name := declName(d)
+				if typeName, _, isMethod := strings.Cut(name, "."); isMethod {
+					name = typeName
+				}
+
+				if td, found := decls[name]; found {
+					td.nodes = append(td.nodes, d)
+					continue
+				}
+
+				td := &templateDecl{name: name, nodes: []ast.Node{d}}
+				decls[name] = td
+				order = append(order, td)
+			case *ast.GenDecl:
+				if d.Tok == token.IMPORT {
+					continue
+				}
+
+				for _, spec := range d.Specs {
+					if errDef, ok := errorDef(spec); ok {
+						templates[errDef.VarName] = Template{Errs: []ErrorDef{errDef}}
+						continue
+					}
+
+					// only errors are grouped, anything else would be
+					// written along with the rest of its group
+					if len(d.Specs) > 1 {
+						return nil, fmt.Errorf("grouped declaration of %v", specName(spec))
+					}
+
+					td := &templateDecl{name: specName(spec), nodes: []ast.Node{d}}
+					decls[td.name] = td
+					order = append(order, td)
+				}
+			}
+		}
+	}
+
+	// names are changed in place so every decl is printed with the names
+	// it has in a generated file
+	for ident, obj := range info.Uses {
+		if obj.Parent() == pkg.Scope() {
+			ident.Name = inlineName(obj.Name())
+		}
+	}
+
+	for ident, obj := range info.Defs {
+		if obj != nil && obj.Parent() == pkg.Scope() {
+			ident.Name = inlineName(obj.Name())
+		}
+	}
+
+	for _, td := range order {
+		t := Template{}
+		seen := make(map[string]bool)
+		var buf bytes.Buffer
+		for _, node := range td.nodes {
+			renameDoc(node, td.name)
+			ast.Inspect(node, func(n ast.Node) bool {
+				ident, ok := n.(*ast.Ident)
+				if !ok {
+					return true
+				}
+
+				switch obj := info.Uses[ident].(type) {
+				case *types.PkgName:
+					if importPath := obj.Imported().Path(); !seen[importPath] {
+						seen[importPath] = true
+						t.Imports = append(t.Imports, importPath)
+					}
+				case nil:
+				default:
+					if obj.Parent() != pkg.Scope() || obj.Name() == td.name || seen[obj.Name()] {
+						return true
+					}
+
+					seen[obj.Name()] = true
+					t.Deps = append(t.Deps, obj.Name())
+				}
+
+				return true
+			})
+
+			err := printer.Fprint(&buf, fset, &printer.CommentedNode{Node: node, Comments: fileOf(files, node).Comments})
+			if err != nil {
+				return nil, err
+			}
+
+			buf.WriteString("\n\n")
+		}
+
+		t.Code = buf.String()
+		templates[td.name] = t
+	}
+
+	return templates, nil
+}
+
+// errorDef returns the error declared by spec if it is an
+// errors.New sentinel.
+func errorDef(spec ast.Spec) (ErrorDef, bool) {
+	vs, ok := spec.(*ast.ValueSpec)
+	if !ok || len(vs.Names) != 1 || len(vs.Values) != 1 || !strings.HasPrefix(vs.Names[0].Name, "Err") {
+		return ErrorDef{}, false
+	}
+
+	call, ok := vs.Values[0].(*ast.CallExpr)
+	if !ok || len(call.Args) != 1 {
+		return ErrorDef{}, false
+	}
+
+	lit, ok := call.Args[0].(*ast.BasicLit)
+	if !ok || lit.Kind != token.STRING {
+		return ErrorDef{}, false
+	}
+
+	desc, err := strconv.Unquote(lit.Value)
+	if err != nil {
+		return ErrorDef{}, false
+	}
+
+	return ErrorDef{VarName: vs.Names[0].Name, Desc: desc}, true
+}
+
+// renameDoc changes the name starting the doc comment of node to its
+// inline name.
+func renameDoc(node ast.Node, name string) {
+	var doc *ast.CommentGroup
+	switch n := node.(type) {
+	case *ast.FuncDecl:
+		doc = n.Doc
+	case *ast.GenDecl:
+		doc = n.Doc
+	}
+
+	if doc == nil || inlineName(name) == name {
+		return
+	}
+
+	first := doc.List[0]
+	if rest, found := strings.CutPrefix(first.Text, "// "+name+" "); found {
+		first.Text = "// " + inlineName(name) + " " + rest
+	}
+}
+
+func fileOf(files []*ast.File, node ast.Node) *ast.File {
+	for _, file := range files {
+		if file.Pos() <= node.Pos() && node.End() <= file.End() {
+			return file
+		}
+	}
+
+	return files[0]
+}
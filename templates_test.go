@@ -0,0 +1,108 @@
+package main
+
+import (
+	"go/format"
+	"strings"
+	"testing"
+)
+
+func TestRuntimeTemplates(t *testing.T) {
+	inline := make(map[string]string)
+	for name, tmpl := range runtimeTemplates {
+		if other, found := inline[inlineName(name)]; found {
+			t.Errorf("%v and %v are both written as %v", name, other, inlineName(name))
+		}
+		inline[inlineName(name)] = name
+
+		for _, dep := range tmpl.Deps {
+			if _, found := runtimeTemplates[dep]; !found {
+				t.Errorf("%v uses %v which is not a template", name, dep)
+			}
+		}
+	}
+
+	for name := range unexportedTemplates {
+		if _, found := runtimeTemplates[name]; !found {
+			t.Errorf("unexported template %v is not declared in envrt", name)
+		}
+	}
+}
+
+func TestTemplateUseAddsDeps(t *testing.T) {
+	templates := &TemplateCache{}
+	templates.Use("ParseRequired")
+
+	for _, name := range []string{"ParseRequired", "parseValue", "lookupKey", "Loader", "ExpandRefs", "FieldError", "Lookuper", "ErrKeyNotFound"} {
+		if _, found := templates.values[name]; !found {
+			t.Errorf("expected %v to be added", name)
+		}
+	}
+
+	code := templates.values["ParseRequired"].Code
+	if !strings.Contains(code, "func parseRequired[T any](ld *loader,") {
+		t.Errorf("expected inline names, got:\n%v", code)
+	}
+
+	if !strings.HasPrefix(code, "// parseRequired finds key") {
+		t.Errorf("expected the doc to use the inline name, got:\n%v", code)
+	}
+}
+
+func TestRewriteBodyRuntime(t *testing.T) {
+	header := "package cfg\n\nimport (\n\"fmt\"\n\"strings\"\n\"github.com/miniscruff/genenv/envrt\"\n)\n\n"
+	body := `
+func describe(c *Config) string {
+	redact := strings.TrimSpace(c.Name)
+	return fmt.Sprint(redact, c.Redact, expandRefs, formatInt(c.Port))
+}
+
+func (c *Config) load(l Lookuper) {}
+
+// formatInt is a helper.
+func formatInt(v int) string {
+	return fmt.Sprint(v)
+}
+
+// Lookuper finds values.
+type Lookuper interface {
+	LookupEnv(key string) (string, bool)
+}
+
+func (l *Lookupers) unrelated() {}
+`
+	helpers := map[string]string{"formatInt": "FormatInt", "Lookuper": "Lookuper", "redact": "Redact", "expandRefs": "ExpandRefs"}
+	drop := map[string]bool{"formatInt": true, "Lookuper": true, "redact": true, "expandRefs": true}
+
+	rewritten, used, err := rewriteBody([]byte(header), []byte(body), drop, helpers)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	got, err := format.Source(rewritten)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for _, want := range []string{
+		"redact := strings.TrimSpace(c.Name)",
+		"fmt.Sprint(redact, c.Redact, expandRefs, envrt.FormatInt(c.Port))",
+		"func (c *Config) load(l envrt.Lookuper) {}",
+		"func (l *Lookupers) unrelated() {}",
+	} {
+		if !strings.Contains(string(got), want) {
+			t.Errorf("expected %q in:\n%v", want, string(got))
+		}
+	}
+
+	for _, dropped := range []string{"is a helper", "func formatInt", "type Lookuper", "finds values"} {
+		if strings.Contains(string(got), dropped) {
+			t.Errorf("expected %q to be dropped from:\n%v", dropped, string(got))
+		}
+	}
+
+	for _, path := range []string{"fmt", "strings", runtimeImport} {
+		if !used[path] {
+			t.Errorf("expected %v to be used", path)
+		}
+	}
+}
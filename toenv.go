@@ -0,0 +1,92 @@
+package main
+
+import (
+	"fmt"
+	"io"
+)
+
+// formatFunc adds the shared func formatting a single value of typeName
+// and returns its name.
+func (f *Field) formatFunc() string {
+	format := convMap[f.typeName].Format
+	f.templates.Use(format)
+	return inlineName(format)
+}
+
+// writeToEnv writes a func adding the env var of each of our fields. The
+// root type gets the exported ToEnv and Environ methods.
+func (b *StructBuilder) writeToEnv(w io.Writer) error {
+	if b.rootType {
+		writeF(w, "func add%[1]vEnv(c *%[1]v, env map[string]string) {\n", b.name)
+	} else {
+		writeF(w, "func add%[1]vEnv(c *%[1]v, env map[string]string, prefix string) {\n", b.name)
+	}
+
+	typeField, hasTypeField := b.fields["Type"]
+	for _, f := range b.order {
+		// only the selected build type option is loaded so only it is added
+		closeIf := false
+		if hasTypeField && f != typeField {
+			writeF(w, "if c.Type == %q {\n", f.envKey)
+			closeIf = true
+		}
+
+		switch {
+		case f.customType && f.pointer:
+			writeF(w, "if c.%[1]v != nil {\nadd%[2]vEnv(c.%[1]v, env, %[3]v)\n}\n", f.goPath, f.typeName, f.keyExpr())
+		case f.customType:
+			writeF(w, "add%vEnv(&c.%v, env, %v)\n", f.typeName, f.goPath, f.keyExpr())
+		default:
+			if _, found := convMap[f.typeName]; !found {
+				return fmt.Errorf("unknown type: %v", f.typeName)
+			}
+
+			if f.slice {
+				b.templates.Use("FormatSlice")
+				writeF(w, "env[%v] = formatSlice(c.%v, %v)\n", f.keyExpr(), f.goPath, f.formatFunc())
+			} else {
+				writeF(w, "env[%v] = %v(c.%v)\n", f.keyExpr(), f.formatFunc(), f.goPath)
+			}
+		}
+
+		if closeIf {
+			writeF(w, "}\n")
+		}
+	}
+
+	writeF(w, "}\n\n")
+
+	if !b.rootType {
+		return nil
+	}
+
+	b.importCache.Add("sort", "sort")
+	writeF(
+		w,
+		`// ToEnv returns the env vars loading a %[1]v equal to c, the inverse of
+		// New%[1]vFrom. Secret values are included.
+		func (c *%[1]v) ToEnv() map[string]string {
+			env := make(map[string]string)
+			add%[1]vEnv(c, env)
+			return env
+		}
+
+		// Environ returns ToEnv as sorted key=value pairs, the format used by
+		// os.Environ and exec.Cmd.Env.
+		func (c *%[1]v) Environ() []string {
+			env := c.ToEnv()
+			environ := make([]string, 0, len(env))
+			for k, v := range env {
+				environ = append(environ, k+"="+v)
+			}
+
+			sort.Strings(environ)
+			return environ
+		}
+
+		`,
+		b.name,
+	)
+
+	return nil
+}
@@ -0,0 +1,71 @@
+package main
+
+import (
+	"io"
+)
+
+// writeKeys writes a func adding every key used by our type.
+func (b *StructBuilder) writeKeys(w io.Writer) {
+	b.templates.Use("KnownKeys")
+
+	if b.rootType {
+		writeF(w, "func add%vKeys(k *knownKeys) {\n", b.name)
+		if b.keyPrefix != "" {
+			writeF(w, "k.Own(%vPrefix)\n", b.name)
+		}
+	} else {
+		writeF(w, "func add%vKeys(k *knownKeys, prefix string) {\nk.Own(prefix)\n", b.name)
+	}
+
+	for _, f := range b.order {
+		if f.customType {
+			writeF(w, "add%vKeys(k, %v)\n", f.typeName, f.keyExpr())
+		} else {
+			writeF(w, "k.Add(%v)\n", f.keyExpr())
+			for _, alias := range f.aliases {
+				writeF(w, "k.Add(%v)\n", f.aliasKeyExpr(alias))
+			}
+		}
+	}
+
+	writeF(w, "}\n\n")
+
+	if !b.rootType {
+		return
+	}
+
+	writeF(
+		w,
+		`// CheckUnknown%[1]v reports env vars under a prefix owned by %[1]v that
+		// no field uses.
+		func CheckUnknown%[1]v() error {
+			return CheckUnknown%[1]vIn(OSLookup)
+		}
+
+		// CheckUnknown%[1]vIn is CheckUnknown%[1]v for any Lookuper able to
+		// list its values, others are never reported as unknown.
+		func CheckUnknown%[1]vIn(l Lookuper) error {
+			k := &knownKeys{}
+			add%[1]vKeys(k)
+			return k.Check(environOf(l))
+		}
+
+		`,
+		b.name,
+	)
+}
+
+// writeStrictCheck writes checking for unknown keys at the end of our
+// root constructor.
+func (b *StructBuilder) writeStrictCheck(w io.Writer) {
+	if !b.strict || !b.rootType {
+		return
+	}
+
+	writeF(
+		w,
+		"if err := CheckUnknown%vIn(ld.Lookup); err != nil {\n%v\n}\n",
+		b.name,
+		failStmt(b.aggregate, "err"),
+	)
+}
@@ -0,0 +1,205 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"reflect"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Check is a single validation rule run after a field is parsed.
+type Check struct {
+	// Cond is a format string of a go expression that is true when the
+	// value is invalid, the value expression is passed as the only arg.
+	Cond string
+	// Pattern is the expression of a pattern tag, checked with a package
+	// level regexp instead of Cond so it is only compiled once
+	Pattern    string
+	Constraint string
+	// Allows checks a parsed default tag the same way Cond checks a loaded
+	// value, so defaults breaking their own checks fail generating
+	Allows func(v string) bool
+}
+
+// loadChecks reads the validation tags of a field, values are verified
+// here so bad tags fail generation instead of the generated code.
+func (f *Field) loadChecks(tags reflect.StructTag) error {
+	isNumber := !f.slice && (f.typeName == "int" || f.typeName == "time.Duration")
+	isString := !f.slice && f.typeName == "string"
+
+	for _, name := range []string{"min", "max"} {
+		value, ok := tags.Lookup(name)
+		if !ok {
+			continue
+		}
+
+		if !isNumber {
+			return fmt.Errorf("%v tag requires a number or duration for field: '%v'", name, f.varName)
+		}
+
+		literal, err := numberLiteral(f.typeName, value)
+		if err != nil {
+			return fmt.Errorf("invalid %v tag for field: '%v': %w", name, f.varName, err)
+		}
+
+		bound, _ := parseNumber(f.typeName, value)
+		op, allows := "<", func(v string) bool {
+			n, err := parseNumber(f.typeName, v)
+			return err == nil && n >= bound
+		}
+		if name == "max" {
+			op, allows = ">", func(v string) bool {
+				n, err := parseNumber(f.typeName, v)
+				return err == nil && n <= bound
+			}
+		}
+
+		f.checks = append(f.checks, Check{
+			Cond:       "%v " + op + " " + literal,
+			Constraint: name + "=" + value,
+			Allows:     allows,
+		})
+	}
+
+	for _, name := range []string{"minlen", "maxlen"} {
+		value, ok := tags.Lookup(name)
+		if !ok {
+			continue
+		}
+
+		if !isString {
+			return fmt.Errorf("%v tag requires a string for field: '%v'", name, f.varName)
+		}
+
+		length, err := strconv.Atoi(value)
+		if err != nil || length < 0 {
+			return fmt.Errorf("invalid %v tag for field: '%v': %v", name, f.varName, value)
+		}
+
+		op, allows := "<", func(v string) bool { return len(v) >= length }
+		if name == "maxlen" {
+			op, allows = ">", func(v string) bool { return len(v) <= length }
+		}
+
+		f.checks = append(f.checks, Check{
+			Cond:       "len(%v) " + op + " " + strconv.Itoa(length),
+			Constraint: name + "=" + value,
+			Allows:     allows,
+		})
+	}
+
+	if pattern, ok := tags.Lookup("pattern"); ok {
+		if !isString {
+			return fmt.Errorf("pattern tag requires a string for field: '%v'", f.varName)
+		}
+
+		re, err := regexp.Compile(pattern)
+		if err != nil {
+			return fmt.Errorf("invalid pattern tag for field: '%v': %w", f.varName, err)
+		}
+
+		f.importCache.Add("regexp", "regexp")
+		f.checks = append(f.checks, Check{
+			Pattern:    pattern,
+			Constraint: "pattern=" + pattern,
+			Allows:     re.MatchString,
+		})
+	}
+
+	if _, ok := tags.Lookup("nonempty"); ok {
+		if !f.slice {
+			return fmt.Errorf("nonempty tag requires a slice for field: '%v'", f.varName)
+		}
+
+		f.checks = append(f.checks, Check{
+			Cond:       "len(%v) == 0",
+			Constraint: "nonempty",
+			Allows:     func(v string) bool { return v != "" },
+		})
+	}
+
+	return nil
+}
+
+// writeChecks writes each check as setting err if the value is invalid,
+// existing errors are left alone.
+func (f *Field) writeChecks(w io.Writer, envKey string) {
+	if len(f.checks) > 0 {
+		f.templates.Use("ValidationError", "FieldError")
+	}
+
+	for _, check := range f.checks {
+		writeF(
+			w,
+			"\nif err == nil && %v {\nerr = %v\n}",
+			f.checkCond(check),
+			f.fieldErrorExpr(envKey, fmt.Sprintf(
+				"&ValidationError{Key: %v, Constraint: %q}",
+				envKey,
+				check.Constraint,
+			)),
+		)
+	}
+}
+
+// checkCond returns the go expression of check that is true when our
+// value is invalid.
+func (f *Field) checkCond(check Check) string {
+	if check.Pattern != "" {
+		return fmt.Sprintf("!%v.MatchString(c.%v)", f.patternVar(), f.goPath)
+	}
+
+	return fmt.Sprintf(check.Cond, "c."+f.goPath)
+}
+
+// patternVar is the name of the package level regexp of our pattern tag.
+func (f *Field) patternVar() string {
+	return unexported(f.structName) + strings.ReplaceAll(f.goPath, ".", "") + "Pattern"
+}
+
+// writePatterns writes the regexp of each pattern tag of our fields,
+// compiled once instead of on every load.
+func (b *StructBuilder) writePatterns(w io.Writer) {
+	for _, f := range b.order {
+		for _, check := range f.checks {
+			if check.Pattern == "" {
+				continue
+			}
+
+			writeF(
+				w,
+				"// %v matches the pattern tag of %v.%v.\nvar %[1]v = regexp.MustCompile(%[4]q)\n\n",
+				f.patternVar(),
+				b.name,
+				f.goPath,
+				check.Pattern,
+			)
+		}
+	}
+}
+
+func numberLiteral(typeName, value string) (string, error) {
+	v, err := parseNumber(typeName, value)
+	if err != nil {
+		return "", err
+	}
+
+	if typeName == "time.Duration" {
+		return fmt.Sprintf("time.Duration(%d)", v), nil
+	}
+
+	return strconv.FormatInt(v, 10), nil
+}
+
+// parseNumber parses value as an int, or the nanoseconds of a duration.
+func parseNumber(typeName, value string) (int64, error) {
+	if typeName == "time.Duration" {
+		d, err := time.ParseDuration(value)
+		return int64(d), err
+	}
+
+	return strconv.ParseInt(value, 10, 64)
+}
@@ -0,0 +1,71 @@
+package main
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestGeneratedChecks(t *testing.T) {
+	generated := mustGenerate(t, `package main
+
+import "time"
+
+type Config struct {
+	Port  int           `+"`default:\"80\" min:\"1\" max:\"65535\"`"+`
+	Wait  time.Duration `+"`default:\"1s\" max:\"1m\"`"+`
+	Name  string        `+"`default:\"app\" minlen:\"2\" maxlen:\"8\" pattern:\"^[a-z%]+$\"`"+`
+	Hosts []string      `+"`default:\"a\" nonempty:\"\"`"+`
+	Db    DbConfig      `+"`env:\"DB,inline\"`"+`
+}
+
+type DbConfig struct {
+	File string `+"`default:\"x.db\" pattern:\"\\\\.db$\"`"+`
+}
+`, GenConfig{})
+
+	hasAll(t, generated,
+		"if err == nil && c.Port < 1 {",
+		"if err == nil && c.Port > 65535 {",
+		`Err: &ValidationError{Key: "PORT", Constraint: "max=65535"}`,
+		"if err == nil && c.Wait > time.Duration(60000000000) {",
+		"if err == nil && len(c.Name) < 2 {",
+		"if err == nil && len(c.Name) > 8 {",
+		"if err == nil && len(c.Hosts) == 0 {",
+		// patterns are compiled once, not on every load
+		"if err == nil && !configNamePattern.MatchString(c.Name) {",
+		`var configNamePattern = regexp.MustCompile("^[a-z%]+$")`,
+		"if err == nil && !configDbFilePattern.MatchString(c.Db.File) {",
+		`var configDbFilePattern = regexp.MustCompile("\\.db$")`,
+	)
+
+	if n := strings.Count(generated, "regexp.MustCompile("); n != 2 {
+		t.Errorf("expected 2 compiled patterns, got %v", n)
+	}
+}
+
+func TestCheckTagErrors(t *testing.T) {
+	for _, tc := range []struct {
+		name    string
+		field   string
+		wantErr string
+	}{
+		{name: "min string", field: "Name string `min:\"1\"`", wantErr: "min tag requires a number or duration for field: 'Name'"},
+		{name: "max slice", field: "Ports []int `max:\"1\"`", wantErr: "max tag requires a number or duration for field: 'Ports'"},
+		{name: "min bad int", field: "Port int `min:\"one\"`", wantErr: "invalid min tag for field: 'Port'"},
+		{name: "max bad duration", field: "Wait time.Duration `max:\"10\"`", wantErr: "invalid max tag for field: 'Wait'"},
+		{name: "minlen int", field: "Port int `minlen:\"1\"`", wantErr: "minlen tag requires a string for field: 'Port'"},
+		{name: "maxlen negative", field: "Name string `maxlen:\"-1\"`", wantErr: "invalid maxlen tag for field: 'Name': -1"},
+		{name: "pattern int", field: "Port int `pattern:\"[0-9]+\"`", wantErr: "pattern tag requires a string for field: 'Port'"},
+		{name: "pattern invalid", field: "Name string `pattern:\"[a-z\"`", wantErr: "invalid pattern tag for field: 'Name'"},
+		{name: "nonempty string", field: "Name string `nonempty:\"\"`", wantErr: "nonempty tag requires a slice for field: 'Name'"},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			// every case uses time so the import is never unused
+			src := "package main\n\nimport \"time\"\n\ntype Config struct {\n" + tc.field + "\nTimeout time.Duration\n}\n"
+			_, err := generate(t, src, GenConfig{})
+			if err == nil || !strings.Contains(err.Error(), tc.wantErr) {
+				t.Fatalf("expected %q, got %v", tc.wantErr, err)
+			}
+		})
+	}
+}
@@ -0,0 +1,196 @@
+package main
+
+import (
+	"io"
+)
+
+// writeFixedChanges writes a func listing the keys of fields tagged
+// reload:"false" that differ between two loads.
+func (b *StructBuilder) writeFixedChanges(w io.Writer) {
+	if !b.writes("watcher") {
+		return
+	}
+
+	if b.rootType {
+		writeF(w, "func changed%[1]vFixed(a, b *%[1]v) []string {\n", b.name)
+	} else {
+		writeF(w, "func changed%[1]vFixed(a, b *%[1]v, prefix string) []string {\n", b.name)
+	}
+
+	writeF(w, "var keys []string\n")
+
+	for _, f := range b.order {
+		switch {
+		case f.customType && f.noReload:
+			b.importCache.Add("reflect", "reflect")
+			writeF(w, "if !reflect.DeepEqual(a.%[1]v, b.%[1]v) {\nkeys = append(keys, %[2]v)\n}\n", f.goPath, f.keyExpr())
+		case f.customType && f.pointer:
+			writeF(
+				w,
+				"if a.%[1]v != nil && b.%[1]v != nil {\nkeys = append(keys, changed%[2]vFixed(a.%[1]v, b.%[1]v, %[3]v)...)\n}\n",
+				f.goPath,
+				f.typeName,
+				f.keyExpr(),
+			)
+		case f.customType:
+			writeF(
+				w,
+				"keys = append(keys, changed%[2]vFixed(&a.%[1]v, &b.%[1]v, %[3]v)...)\n",
+				f.goPath,
+				f.typeName,
+				f.keyExpr(),
+			)
+		case f.noReload && f.slice:
+			b.importCache.Add("reflect", "reflect")
+			writeF(w, "if !reflect.DeepEqual(a.%[1]v, b.%[1]v) {\nkeys = append(keys, %[2]v)\n}\n", f.goPath, f.keyExpr())
+		case f.noReload:
+			writeF(w, "if a.%[1]v != b.%[1]v {\nkeys = append(keys, %[2]v)\n}\n", f.goPath, f.keyExpr())
+		}
+	}
+
+	writeF(w, "return keys\n}\n\n")
+}
+
+// writeWatcher writes the holder of our root config able to reload it.
+func (b *StructBuilder) writeWatcher(w io.Writer) {
+	if !b.rootType || !b.writes("watcher") {
+		return
+	}
+
+	b.templates.Use("ModTimes", "ModTimesChanged", "ErrReloadFixed")
+	for _, imp := range []string{"context", "errors", "fmt", "os", "os/signal", "strings", "sync", "sync/atomic", "syscall", "time"} {
+		b.importCache.Add(imp, imp)
+	}
+
+	writeF(
+		w,
+		`// loaded%[1]v is a %[1]v along with the sources it was loaded from.
+		type loaded%[1]v struct {
+			config  *%[1]v
+			sources FieldSources
+		}
+
+		// %[1]vWatcher holds the current %[1]v and replaces it when reloaded,
+		// it is safe to use from several goroutines.
+		type %[1]vWatcher struct {
+			// PollInterval is how often watched files are checked for changes,
+			// zero or less disables polling
+			PollInterval time.Duration
+
+			current atomic.Pointer[loaded%[1]v]
+			load    func() (*%[1]v, FieldSources, error)
+			mu      sync.Mutex
+			subs    []func(old, new *%[1]v)
+		}
+
+		// New%[1]vWatcher loads the first %[1]v with load, which is called
+		// again on every reload. Load%[1]v has the same signature once given
+		// a Lookuper.
+		func New%[1]vWatcher(load func() (*%[1]v, FieldSources, error)) (*%[1]vWatcher, error) {
+			c, sources, err := load()
+			if err != nil {
+				return nil, err
+			}
+
+			w := &%[1]vWatcher{
+				PollInterval: 2 * time.Second,
+				load:         load,
+			}
+			w.current.Store(&loaded%[1]v{config: c, sources: sources})
+
+			return w, nil
+		}
+
+		// Get returns the current %[1]v, it must not be modified.
+		func (w *%[1]vWatcher) Get() *%[1]v {
+			return w.current.Load().config
+		}
+
+		// Loaded returns the current %[1]v and the sources it was loaded
+		// from, neither must be modified.
+		func (w *%[1]vWatcher) Loaded() (*%[1]v, FieldSources) {
+			loaded := w.current.Load()
+			return loaded.config, loaded.sources
+		}
+
+		// Explain reports where each key of the current %[1]v was loaded
+		// from, it must not be modified.
+		func (w *%[1]vWatcher) Explain() FieldSources {
+			return w.current.Load().sources
+		}
+
+		// Subscribe calls fn with the old and new %[1]v after each successful
+		// reload, fn must not reload the watcher itself.
+		func (w *%[1]vWatcher) Subscribe(fn func(old, new *%[1]v)) {
+			w.mu.Lock()
+			defer w.mu.Unlock()
+
+			w.subs = append(w.subs, fn)
+		}
+
+		// Reload loads %[1]v again and swaps it in if it loaded, keeping the
+		// current one otherwise. Changing a field tagged reload:"false" fails
+		// the reload.
+		func (w *%[1]vWatcher) Reload() error {
+			w.mu.Lock()
+			defer w.mu.Unlock()
+
+			next, sources, err := w.load()
+			if err != nil {
+				return err
+			}
+
+			prev := w.current.Load().config
+			if keys := changed%[1]vFixed(prev, next); len(keys) > 0 {
+				return fmt.Errorf("%%w: %%v", ErrReloadFixed, strings.Join(keys, ", "))
+			}
+
+			w.current.Store(&loaded%[1]v{config: next, sources: sources})
+			for _, fn := range w.subs {
+				fn(prev, next)
+			}
+
+			return nil
+		}
+
+		// Watch reloads on SIGHUP or when one of files changes until ctx is
+		// done, errors from failed reloads are passed to onErr if not nil.
+		func (w *%[1]vWatcher) Watch(ctx context.Context, onErr func(error), files ...string) {
+			hup := make(chan os.Signal, 1)
+			signal.Notify(hup, syscall.SIGHUP)
+			defer signal.Stop(hup)
+
+			// a nil channel never receives, so only SIGHUP reloads when
+			// polling is disabled or there is nothing to poll
+			var tick <-chan time.Time
+			if w.PollInterval > 0 && len(files) > 0 {
+				ticker := time.NewTicker(w.PollInterval)
+				defer ticker.Stop()
+				tick = ticker.C
+			}
+
+			times := modTimes(files)
+			for {
+				select {
+				case <-ctx.Done():
+					return
+				case <-hup:
+				case <-tick:
+					next := modTimes(files)
+					if !modTimesChanged(times, next) {
+						continue
+					}
+
+					times = next
+				}
+
+				if err := w.Reload(); err != nil && onErr != nil {
+					onErr(err)
+				}
+			}
+		}
+
+		`,
+		b.name,
+	)
+}
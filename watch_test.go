@@ -0,0 +1,70 @@
+package main
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestWatcherOptIn(t *testing.T) {
+	src := "package main\n\ntype Config struct {\nPort int `default:\"80\" reload:\"false\"`\n}\n"
+
+	generated := mustGenerate(t, src, GenConfig{})
+	hasNone(t, generated, "ConfigWatcher", "changedConfigFixed", "os/signal", "syscall")
+
+	_, err := generate(t, src, GenConfig{With: []string{"watch"}})
+	if err == nil || !strings.Contains(err.Error(), "unknown output 'watch'") {
+		t.Fatalf("expected an unknown output error, got %v", err)
+	}
+}
+
+func TestGeneratedWatcher(t *testing.T) {
+	got := runGenerated(t, `package main
+
+type Config struct {
+	Port  int    `+"`default:\"80\" reload:\"false\"`"+`
+	Level string `+"`default:\"info\"`"+`
+}
+`, GenConfig{With: []string{"watcher"}}, `package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+)
+
+func main() {
+	env := MapLookup{}
+	w, err := NewConfigWatcher(func() (*Config, FieldSources, error) {
+		return LoadConfig(env)
+	})
+	if err != nil {
+		panic(err)
+	}
+
+	// polling is skipped instead of panicking without an interval or files
+	for _, interval := range []time.Duration{0, -time.Second, time.Millisecond} {
+		w.PollInterval = interval
+		ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+		w.Watch(ctx, nil, "missing.env")
+		w.Watch(ctx, nil)
+		cancel()
+	}
+
+	env["LEVEL"] = "debug"
+	fmt.Println(w.Reload(), w.Get().Level)
+
+	// sources are swapped along with the config they belong to
+	c, sources := w.Loaded()
+	fmt.Println(c.Level, sources[1].Value, sources[1].Source)
+
+	env["PORT"] = "8080"
+	fmt.Println(errors.Is(w.Reload(), ErrReloadFixed), w.Get().Port)
+}
+`)
+
+	want := "<nil> debug\ndebug debug lookup\ntrue 80"
+	if got != want {
+		t.Fatalf("got:\n%v\nwant:\n%v", got, want)
+	}
+}